@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/sgaunet/ekspodlogs/internal/config"
+)
+
+// loadConfig reads the configuration file given by --config, or the default one
+func loadConfig() (*config.Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	return config.Load(path)
+}
+
+// configPath returns the path of the configuration file given by --config, or the default one
+func configPath() (string, error) {
+	if configFile != "" {
+		return configFile, nil
+	}
+	return config.DefaultPath()
+}
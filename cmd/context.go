@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sgaunet/ekspodlogs/internal/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// contextEnvVar is the environment variable giving the context, instead of the current one of the config file
+const contextEnvVar = "EKSPODLOGS_CONTEXT"
+
+// selectedContext returns the name of the context given by --context, EKSPODLOGS_CONTEXT
+// or the current context of the config file, empty if none
+func selectedContext(cfg *config.Config) string {
+	if contextName != "" {
+		return contextName
+	}
+	if name := os.Getenv(contextEnvVar); name != "" {
+		return name
+	}
+	return cfg.CurrentContext
+}
+
+// applyContext sets the flags of the command not given on the command line to the values of the context
+// The environment variables of AWS and EKSPODLOGS_DB take precedence over the context.
+func applyContext(cmd *cobra.Command) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	name := selectedContext(cfg)
+	if name == "" {
+		return nil
+	}
+	c, err := cfg.GetContext(name)
+	if err != nil {
+		return err
+	}
+
+	values := map[string][]string{
+		"profile":           {c.Profile},
+		"region":            {c.Region},
+		"role-arn":          c.RoleARNs,
+		"external-id":       {c.ExternalID},
+		"role-session-name": {c.RoleSessionName},
+		"mfa-serial":        {c.MFASerial},
+		"group":             {c.Group},
+		"namespace":         {c.Namespace},
+		"container":         {c.Container},
+		"exclude-container": c.ExcludeContainers,
+		"output":            {c.Output},
+	}
+	if os.Getenv("AWS_PROFILE") != "" {
+		delete(values, "profile") // the profile of the environment takes precedence
+	}
+	if os.Getenv("AWS_REGION") != "" || os.Getenv("AWS_DEFAULT_REGION") != "" {
+		delete(values, "region")
+	}
+	for flag, vals := range values {
+		f := cmd.Flags().Lookup(flag)
+		if f == nil || f.Changed {
+			continue
+		}
+		for _, v := range vals {
+			if v == "" {
+				continue
+			}
+			if err := cmd.Flags().Set(flag, v); err != nil {
+				return fmt.Errorf("invalid %s of context %s: %w", flag, name, err)
+			}
+		}
+	}
+
+	if c.DB != "" {
+		contextDB, err = expandHome(c.DB)
+		if err != nil {
+			return fmt.Errorf("invalid db of context %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// expandHome replaces the leading ~ of a path by the home directory
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// contextCmd represents the context command
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "manage the contexts of the config file",
+	Long: `manage the contexts of the config file
+
+A context bundles the default values of the flags of a cluster: profile, region, log group,
+filters on the pods, output format and database. It is selected by --context, EKSPODLOGS_CONTEXT
+or the current_context of the config file. The flags and the environment variables take precedence.`,
+	// The context is not applied to the commands managing it, an invalid context can be replaced
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+}
+
+// contextUseCmd represents the context use command
+var contextUseCmd = &cobra.Command{
+	Use:   "use NAME",
+	Short: "set the current context of the config file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if _, err := cfg.GetContext(args[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		path, err := configPath()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if err := config.SetCurrentContext(path, args[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Switched to context %s\n", args[0])
+	},
+}
+
+// contextListCmd represents the context list command
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the contexts of the config file, the current one is marked with *",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if len(cfg.Contexts) == 0 {
+			fmt.Println("No context found in the config file")
+			return
+		}
+		names := make([]string, 0, len(cfg.Contexts))
+		for name := range cfg.Contexts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		current := selectedContext(cfg)
+		for _, name := range names {
+			mark := " "
+			if name == current {
+				mark = "*"
+			}
+			c := cfg.Contexts[name]
+			fmt.Printf("%s %-20s %-15s %-15s %s\n", mark, name, c.Profile, c.Region, c.Group)
+		}
+	},
+}
+
+// contextShowCmd represents the context show command
+var contextShowCmd = &cobra.Command{
+	Use:   "show [NAME]",
+	Short: "print a context of the config file, the current one by default",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		name := selectedContext(cfg)
+		if len(args) == 1 {
+			name = args[0]
+		}
+		if name == "" {
+			fmt.Println("No current context")
+			return
+		}
+		c, err := cfg.GetContext(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		out, err := yaml.Marshal(map[string]config.Context{name: c})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Print(string(out))
+	},
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/internal/app"
+	"github.com/sgaunet/ekspodlogs/internal/config"
+	"github.com/sgaunet/ekspodlogs/internal/daemon"
+	"github.com/sgaunet/ekspodlogs/pkg/parser"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+	"github.com/sgaunet/ekspodlogs/pkg/views"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// daemonTargets returns the targets of the config file, or the target given by the flags
+func daemonTargets(cfg *config.Config) []daemon.Target {
+	if len(cfg.Daemon.Targets) == 0 {
+		opts := currentAWSOptions(ssoProfile)
+		return []daemon.Target{{
+			Profile:         opts.Profile,
+			Region:          opts.Region,
+			RoleARNs:        opts.RoleARNs,
+			ExternalID:      opts.ExternalID,
+			RoleSessionName: opts.RoleSessionName,
+			MFASerial:       opts.MFASerial,
+			Group:           groupName,
+			Filter:          currentLogFilter(),
+		}}
+	}
+	targets := make([]daemon.Target, 0, len(cfg.Daemon.Targets))
+	for _, t := range cfg.Daemon.Targets {
+		targets = append(targets, daemon.Target{
+			Profile:         t.Profile,
+			Region:          t.Region,
+			RoleARNs:        t.RoleARNs,
+			ExternalID:      t.ExternalID,
+			RoleSessionName: t.RoleSessionName,
+			MFASerial:       t.MFASerial,
+			Group:           t.Group,
+			Filter: sqlite.LogFilter{
+				PodName:            t.Pod,
+				Namespace:          t.Namespace,
+				Container:          t.Container,
+				ExcludedContainers: t.ExcludeContainers,
+			},
+		})
+	}
+	return targets
+}
+
+// targetAWSOptions returns the options of the connection to the AWS API of the target
+func targetAWSOptions(target daemon.Target) AWSOptions {
+	return AWSOptions{
+		Profile:         target.Profile,
+		Region:          target.Region,
+		RoleARNs:        target.RoleARNs,
+		ExternalID:      target.ExternalID,
+		RoleSessionName: target.RoleSessionName,
+		MFASerial:       target.MFASerial,
+	}
+}
+
+// key identifies the connection, the targets with the same options share their App
+func (o AWSOptions) key() string {
+	return strings.Join([]string{o.Profile, o.Region, strings.Join(o.RoleARNs, ","), o.ExternalID, o.RoleSessionName, o.MFASerial}, "\x00")
+}
+
+// targetSyncer syncs the targets of the daemon, with one App per connection to AWS
+type targetSyncer struct {
+	apps      map[string]*app.App
+	groups    map[string]string // log groups found automatically by connection
+	logger    *logrus.Logger
+	logParser parser.Parser
+	keep      time.Duration
+}
+
+// app returns the App of the connection, created at the first call
+func (t *targetSyncer) app(ctx context.Context, opts AWSOptions) (*app.App, error) {
+	if a, ok := t.apps[opts.key()]; ok {
+		return a, nil
+	}
+	cfg, err := InitAWSConfig(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	a := app.New(cfg, opts.Profile, s, views.NewTerminalView())
+	a.SetLogger(t.logger)
+	a.SetWorkers(workers)
+	a.SetParser(t.logParser)
+	// The account is saved with the logs
+	if _, err := a.GetIdentity(ctx); err != nil {
+		return nil, err
+	}
+	t.apps[opts.key()] = a
+	return a, nil
+}
+
+// sync fetches the events of the target from its watermark, or from --keep before now the first time
+func (t *targetSyncer) sync(ctx context.Context, target daemon.Target) error {
+	opts := targetAWSOptions(target)
+	a, err := t.app(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if target.Group == "" {
+		if target.Group = t.groups[opts.key()]; target.Group == "" {
+			if target.Group, err = a.FindLogGroupAuto(ctx); err != nil {
+				return err
+			}
+			if target.Group == "" {
+				return errors.New("log group not found automatically (add option -g or the group of the target)")
+			}
+			t.groups[opts.key()] = target.Group
+		}
+	}
+	var pattern string
+	if t.logParser.Name() != parser.RawName {
+		pattern = app.FilterPattern(target.Filter)
+	}
+	a.SetFilterPattern(pattern)
+
+	end := time.Now()
+	begin := end.Add(-t.keep)
+	watermark, found, err := s.GetSyncWatermark(ctx, a.Source(target.Group), target.Filter)
+	if err != nil {
+		return err
+	}
+	if found && watermark.Add(-incrementalLookback).After(begin) {
+		begin = watermark.Add(-incrementalLookback)
+	}
+	res, err := a.FetchEvents(ctx, target.Group, target.Filter, begin, end)
+	if err != nil {
+		return err
+	}
+	if err := s.AddSyncWindow(ctx, a.Source(target.Group), target.Filter, begin, end, res.EventCount); err != nil {
+		return err
+	}
+	watermark = syncWatermark(res.LastEventTime, end, time.Now())
+	if err := s.SetSyncWatermark(ctx, a.Source(target.Group), target.Filter, watermark); err != nil {
+		return err
+	}
+	t.logger.Infof("Synced %s: %d events", target, res.EventCount)
+	return nil
+}
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "keeps the local database synchronised with the logs of cloudwatch",
+	Long: `keeps the local database synchronised with the logs of cloudwatch
+
+The targets (connection to AWS, log group and filter on the pods) are read from the daemon section
+of the config file, or given by the flags. Each target is synced every --interval from
+its last event, the events of the last --keep are synced the first time.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		// The flags take precedence over the config file
+		if !cmd.Flags().Changed("interval") && cfg.Daemon.Interval != "" {
+			daemonInterval = cfg.Daemon.Interval
+		}
+		if !cmd.Flags().Changed("keep") && cfg.Daemon.Keep != "" {
+			daemonKeep = cfg.Daemon.Keep
+		}
+		interval, err := parseDuration(daemonInterval)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		keep, err := parseDuration(daemonKeep)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if interval <= 0 || keep <= 0 {
+			fmt.Fprintln(os.Stderr, "--interval and --keep must be positive")
+			os.Exit(1)
+		}
+		logParser, err := parser.ByName(parserName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		InitDB() // Initialize the database and exit if an error occurs
+
+		if pidFile == "" {
+			pidFile = DBPath + ".daemon.pid"
+		}
+		pid, err := daemon.AcquirePIDFile(pidFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		// Set up signal handling for graceful shutdown
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+
+		// Create a context that will be canceled when a signal is received
+		ctx, cancel := context.WithCancel(ctx)
+
+		// Start a goroutine to handle signals
+		// The daemon stops at the cancellation, the pid file is removed before exiting
+		go func() {
+			sig := <-sigCh
+			fmt.Fprintf(os.Stderr, "Received signal %v, shutting down gracefully...\n", sig)
+			cancel()
+		}()
+
+		// Ensure the pid file is removed and the database is closed when the function returns
+		defer func() {
+			cancel()
+			if err := pid.Release(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error releasing pid file: %v\n", err)
+			}
+			if err := s.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing database: %v\n", err)
+			}
+		}()
+
+		// The progress of the syncs is logged on stderr
+		logger := NewLoggerWithDebug(debug)
+		logger.SetOutput(os.Stderr)
+		syncer := &targetSyncer{
+			apps:      make(map[string]*app.App),
+			groups:    make(map[string]string),
+			logger:    logger,
+			logParser: logParser,
+			keep:      keep,
+		}
+		targets := daemonTargets(cfg)
+		logger.Infof("Syncing %d targets every %s (pid file %s)", len(targets), interval, pidFile)
+		if err := daemon.New(targets, interval, syncer.sync, logger).Run(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	},
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+	"github.com/spf13/cobra"
+)
+
+// dbCmd represents the db command
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "manage the schema of the local database",
+	Long: `manage the schema of the local database
+
+The pending migrations are applied automatically each time the database is opened,
+a copy of the database is taken before (<database>.backup-<version>).`,
+}
+
+// dbMigrateCmd represents the db migrate command
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "apply the pending migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		st := openDBWithoutMigration()
+		defer st.Close()
+
+		pending, err := st.PendingMigrations(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if len(pending) == 0 {
+			fmt.Println("No pending migration")
+			return
+		}
+		backupDB(ctx, st)
+		if err := st.Migrate(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// dbRollbackCmd represents the db rollback command
+var dbRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "revert the last migration applied",
+	Long: `revert the last migration applied
+
+The migration is applied again by the next command opening the database,
+this command is meant to downgrade the database before installing a previous version of ekspodlogs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		st := openDBWithoutMigration()
+		defer st.Close()
+
+		backupDB(ctx, st)
+		if err := st.Rollback(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// dbVersionCmd represents the db version command
+var dbVersionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "print the schema version of the database and the migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		st := openDBWithoutMigration()
+		defer st.Close()
+
+		current, err := st.SchemaVersion(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		latest, err := sqlite.LatestSchemaVersion()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if current == "" {
+			current = "none"
+		}
+		fmt.Printf("Database: %s\n", DBPath)
+		fmt.Printf("Schema version: %s (latest: %s)\n", current, latest)
+		migrations, err := st.Migrations()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		for _, m := range migrations {
+			applied := " "
+			if m.Applied {
+				applied = "X"
+			}
+			fmt.Printf("[%s] %s\n", applied, m.Name)
+		}
+	},
+}
+
+// dbWorkspacesCmd represents the db workspaces command
+var dbWorkspacesCmd = &cobra.Command{
+	Use:   "workspaces",
+	Short: "list the workspaces and the size of their database",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := workspacesDir()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.db"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			fmt.Println("No workspace found")
+			return
+		}
+		for _, f := range files {
+			fi, err := os.Stat(f)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			fmt.Printf("%-30s %10s  %s\n", strings.TrimSuffix(filepath.Base(f), ".db"), formatSize(fi.Size()), f)
+		}
+	},
+}
+
+// openDBWithoutMigration opens the database without applying the pending migrations
+// In case of error, it will exit the program
+func openDBWithoutMigration() *sqlite.Storage {
+	var err error
+	DBPath, err = currentDBPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to get the DB path: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(filepath.Dir(DBPath), 0o700); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create the directory of the database: %s\n", err.Error())
+		os.Exit(1)
+	}
+	st, err := sqlite.NewStorage(DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to open the sqlite storage: %s\n", err.Error())
+		os.Exit(1)
+	}
+	return st
+}
+
+// backupDB copies the database before changing its schema
+// In case of error, it will exit the program
+func backupDB(ctx context.Context, st *sqlite.Storage) {
+	current, err := st.SchemaVersion(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if current == "" {
+		return
+	}
+	backup := backupPath(DBPath, current)
+	if err := st.Backup(ctx, backup); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("Backup of the database: %s\n", backup)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// dbEnvVar is the environment variable giving the path of the database
+const dbEnvVar = "EKSPODLOGS_DB"
+
+// workspacePattern matches the names of the workspaces, they are used as file names
+var workspacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// dataDir returns the directory of the databases:
+// $XDG_DATA_HOME/ekspodlogs, or ~/.local/share/ekspodlogs if XDG_DATA_HOME is not set
+func dataDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", fmt.Errorf("neither XDG_DATA_HOME nor HOME is set, use --db or %s to give the path of the database", dbEnvVar)
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "ekspodlogs"), nil
+}
+
+// workspacesDir returns the directory of the databases of the workspaces
+func workspacesDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "workspaces"), nil
+}
+
+// WorkspaceDBPath returns the path of the database of a named workspace
+func WorkspaceDBPath(name string) (string, error) {
+	if !workspacePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid workspace name %q, only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+	dir, err := workspacesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".db"), nil
+}
+
+// currentDBPath returns the path of the database given by --db, --workspace, EKSPODLOGS_DB
+// or the context, or the default one
+func currentDBPath() (string, error) {
+	switch {
+	case dbFile != "" && workspace != "":
+		return "", errors.New("--db and --workspace can not be combined")
+	case dbFile != "":
+		return dbFile, nil
+	case workspace != "":
+		return WorkspaceDBPath(workspace)
+	case os.Getenv(dbEnvVar) != "":
+		return os.Getenv(dbEnvVar), nil
+	case contextDB != "":
+		return contextDB, nil
+	}
+	return DefaultDBPath()
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/internal/app"
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+// followLookback is the period fetched again at each poll of --follow
+// CloudWatch may ingest the events a few seconds after their timestamp, the events
+// already saved are ignored by the database.
+const followLookback = time.Minute
+
+// checkFollowOptions returns an error if an option of req can not be combined with --follow
+func checkFollowOptions() error {
+	if followInterval <= 0 {
+		return errors.New("--follow-interval must be positive")
+	}
+	switch {
+	case search != "":
+		return errors.New("--follow can not be combined with --search")
+	case reverseOrder:
+		return errors.New("--follow can not be combined with --reverse")
+	case limitLogs > 0 || offsetLogs > 0 || headLogs > 0:
+		return errors.New("--follow can not be combined with --limit, --offset or --head")
+	case endDate != "" || untilDate != "" || aroundDate != "":
+		return errors.New("--follow can not be combined with -e, --until or --around, it ends now")
+	case strings.ToLower(outputFormat) == outputJSON:
+		return errors.New("--follow can not be combined with --output json, use ndjson")
+	}
+	return nil
+}
+
+// followLogs polls CloudWatch every --follow-interval, saves the new events in the database
+// and writes the logs of targets saved after the log afterID until the context is canceled.
+// The events are fetched from the log group and filtered on their pods and containers, the other
+// fields of the filter only select the logs printed. The restarted containers and the new pods
+// matching the filter are followed as well.
+// The first poll fetches the events from followLookback before now at most: the period between
+// from and then, on a database not synchronised, is not fetched and would be printed out of order.
+func followLogs(ctx context.Context, a *app.App, groupName string, targets sqlite.Targets, filter sqlite.LogFilter, from time.Time, afterID int64, write func(database.Log, [][]int) error, out logWriter) error {
+	// The account is saved with the logs and the synced periods
+	if _, err := a.GetIdentity(ctx); err != nil {
+		return err
+	}
+	syncFilter := filter.SyncFilter()
+	first := true
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		begin := from.Add(-followLookback)
+		if limit := now.Add(-followLookback); first && begin.Before(limit) {
+			begin = limit
+		}
+		res, err := a.FetchEvents(ctx, groupName, syncFilter, begin, now)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// Keep following, the next poll fetches the period again
+			fmt.Fprintf(os.Stderr, "failed to fetch the logs: %v\n", err)
+			continue
+		}
+		first = false
+		if err := s.AddSyncWindow(ctx, a.Source(groupName), syncFilter, begin, now, res.EventCount); err != nil {
+			return err
+		}
+		from = now
+
+		b, e, err := ConvertTimeToCarbon(begin, now)
+		if err != nil {
+			return err
+		}
+		err = a.IterateEvents(ctx, targets, filter, b, e, sqlite.IterateOptions{AfterID: afterID}, func(l database.Log) error {
+			afterID = max(afterID, l.ID)
+			return write(l, nil)
+		})
+		if err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return fmt.Errorf("failed to write logs: %w", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "apply the retention and compact the local database",
+	Long: `apply the retention and compact the local database
+
+The logs exceeding the retention of the config file, or of --max-age, --max-rows and
+--max-size, are removed. The database is then rebuilt (VACUUM) to give the free space
+back to the file system and its statistics are updated (PRAGMA optimize).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		retention, err := currentRetention(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		InitDB() // Initialize the database and exit if an error occurs
+
+		// Set up signal handling for graceful shutdown
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+
+		// Create a context that will be canceled when a signal is received
+		ctx, cancel := context.WithCancel(ctx)
+
+		// Start a goroutine to handle signals
+		go func() {
+			sig := <-sigCh
+			fmt.Fprintf(os.Stderr, "Received signal %v, shutting down gracefully...\n", sig)
+			// Cancel the context to signal all operations to stop
+			cancel()
+			os.Exit(0)
+		}()
+
+		// Ensure database is closed when the function returns normally
+		defer func() {
+			// Cancel the context and signal handler
+			cancel()
+			// Close database connection
+			if err := s.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing database: %v\n", err)
+			}
+		}()
+
+		before, err := s.FileSize()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if retention.IsEmpty() {
+			fmt.Println("No retention configured, no log removed")
+		} else if err := applyRetention(ctx, retention); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if err := s.Vacuum(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		after, err := s.FileSize()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("Database size: %s -> %s\n", formatSize(before), formatSize(after))
+	},
+}
@@ -6,48 +6,167 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/dromara/carbon/v2"
 	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
 	"github.com/sirupsen/logrus"
 )
 
-// ConvertTimeToCarbon converts the begin and end date to carbon.Carbon, treating inputs as UTC
-func ConvertTimeToCarbon(beginDate, endDate string) (*carbon.Carbon, *carbon.Carbon, error) {
-	b := carbon.Parse(beginDate).SetTimezone("UTC")
-	if b.Error != nil {
-		return nil, nil, fmt.Errorf("invalid begin date: %w", b.Error)
-	}
-	e := carbon.Parse(endDate).SetTimezone("UTC")
-	if e.Error != nil {
-		return nil, nil, fmt.Errorf("invalid end date: %w", e.Error)
-	}
-	if b.Gt(e) {
+// ConvertTimeToCarbon converts the begin and end date to carbon.Carbon in UTC
+func ConvertTimeToCarbon(begin, end time.Time) (*carbon.Carbon, *carbon.Carbon, error) {
+	if begin.After(end) {
 		return nil, nil, errors.New("begin date is after end date")
 	}
+	b := carbon.CreateFromStdTime(begin.UTC(), carbon.UTC)
+	e := carbon.CreateFromStdTime(end.UTC(), carbon.UTC)
 	return b, e, nil
 }
 
+// incrementalLookback is the period before the watermark fetched again by an incremental sync
+// CloudWatch may ingest the events a few seconds after their timestamp, the events
+// already saved are ignored by the database.
+const incrementalLookback = time.Minute
+
+// IncrementalPeriod computes the period to synchronise in incremental mode
+// The period begins incrementalLookback before the watermark recorded by the previous incremental
+// sync of the same source and filter. The begin date is only required for the first sync.
+// If the end date is zero, the period ends now.
+func IncrementalPeriod(ctx context.Context, st *sqlite.Storage, src sqlite.Source, filter sqlite.LogFilter, begin, end time.Time) (*carbon.Carbon, *carbon.Carbon, error) {
+	watermark, found, err := st.GetSyncWatermark(ctx, src, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found && begin.IsZero() {
+		return nil, nil, errors.New("no previous incremental sync found, begin date must be specified")
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if found {
+		// The watermark takes precedence over the begin date
+		begin = watermark.Add(-incrementalLookback)
+	}
+	return ConvertTimeToCarbon(begin, end)
+}
+
+// syncWatermark returns the watermark recorded by an incremental sync of the period ending at end
+// The watermark is the end of the period, or the last event saved if it is later, but at most
+// incrementalLookback before now: the period of a sync without new events is not fetched again.
+func syncWatermark(lastEventTime, end, now time.Time) time.Time {
+	watermark := end
+	if lastEventTime.After(watermark) {
+		watermark = lastEventTime
+	}
+	if limit := now.Add(-incrementalLookback); watermark.After(limit) {
+		watermark = limit
+	}
+	return watermark
+}
+
+// currentLogFilter returns the filter on the pods given by the flags
+func currentLogFilter() sqlite.LogFilter {
+	f := sqlite.LogFilter{
+		PodName:            podName,
+		Namespace:          namespaceFilter,
+		Container:          containerFilter,
+		ExcludedContainers: excludedContainers,
+		Image:              imageFilter,
+		Node:               nodeFilter,
+		Labels:             labelFilters,
+		Grep:               grepPatterns,
+		GrepV:              grepVPatterns,
+	}
+	if stderrOnly {
+		f.Stream = "stderr"
+	}
+	return f
+}
+
+// checkLabelFilters returns an error if a label filter is not formatted as key=value
+func checkLabelFilters(labels []string) error {
+	for _, l := range labels {
+		if key, _, ok := strings.Cut(l, "="); !ok || key == "" {
+			return fmt.Errorf("invalid label %q, expected key=value", l)
+		}
+	}
+	return nil
+}
+
+// AWSOptions are the options of the connection to the AWS API
+type AWSOptions struct {
+	Profile         string   // Shared config profile, the default one if empty
+	Region          string   // Region, the one of the environment or of the profile if empty
+	RoleARNs        []string // Roles assumed in turn with the credentials of the profile
+	ExternalID      string   // External ID of the last role
+	RoleSessionName string   // Name of the sessions of the roles
+	MFASerial       string   // Serial number of the MFA device of the first role, the token is read on the terminal
+}
+
+// currentAWSOptions returns the options of the connection to the AWS API given by the flags
+func currentAWSOptions(profile string) AWSOptions {
+	return AWSOptions{
+		Profile:         profile,
+		Region:          awsRegion,
+		RoleARNs:        roleARNs,
+		ExternalID:      externalID,
+		RoleSessionName: roleSessionName,
+		MFASerial:       mfaSerial,
+	}
+}
+
 // InitAWSConfig initializes the AWS SDK configuration
-// If the ssoProfile is empty, it will use the default profile
-func InitAWSConfig(ctx context.Context, profile string) (cfg aws.Config, err error) {
-	if len(ssoProfile) == 0 {
-		cfg, err = config.LoadDefaultConfig(ctx)
-	} else {
+// If the profile is empty, it will use the default profile
+// If roles are given, they are assumed in turn, starting with the credentials of the profile
+func InitAWSConfig(ctx context.Context, opts AWSOptions) (cfg aws.Config, err error) {
+	var loadOpts []func(*config.LoadOptions) error
+	if len(opts.Profile) != 0 {
 		// Try to connect with the SSO profile put in parameter
-		cfg, err = config.LoadDefaultConfig(
-			ctx,
-			config.WithSharedConfigProfile(ssoProfile),
-		)
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(opts.Profile))
+	}
+	if len(opts.Region) != 0 {
+		loadOpts = append(loadOpts, config.WithRegion(opts.Region))
 	}
+	cfg, err = config.LoadDefaultConfig(ctx, loadOpts...)
 	if err != nil {
 		return aws.Config{}, fmt.Errorf("unable to load SDK config: %w", err)
 	}
+	for i, roleARN := range opts.RoleARNs {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = opts.RoleSessionName
+			if o.RoleSessionName == "" {
+				o.RoleSessionName = "ekspodlogs"
+			}
+			if i == len(opts.RoleARNs)-1 && opts.ExternalID != "" {
+				o.ExternalID = aws.String(opts.ExternalID)
+			}
+			if i == 0 && opts.MFASerial != "" {
+				o.SerialNumber = aws.String(opts.MFASerial)
+				o.TokenProvider = mfaTokenProvider
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
 	return cfg, nil
 }
 
+// mfaTokenProvider reads the MFA token on the terminal, the prompt is written on stderr
+// to keep the output of the commands clean
+func mfaTokenProvider() (string, error) {
+	fmt.Fprint(os.Stderr, "MFA token code: ")
+	var token string
+	if _, err := fmt.Fscanln(os.Stdin, &token); err != nil {
+		return "", fmt.Errorf("failed to read the MFA token: %w", err)
+	}
+	return token, nil
+}
+
 // NewLogger creates a new logger
 // The debugLevel is the variable to set the log level
 // It can be "info", "warn", "error" or "debug"
@@ -107,54 +226,86 @@ func NewLoggerWithDebug(enableDebug bool) *logrus.Logger {
 	return appLog
 }
 
-// DefaultDBPath returns the default path of the database
+// DefaultDBPath returns the default path of the database:
+// ~/.ekspodlogs.db if it exists (created by the previous versions), or ekspodlogs.db in the data directory
 func DefaultDBPath() (string, error) {
-	homeDir := os.Getenv("HOME")
-	if homeDir == "" {
-		return "", errors.New("HOME environment variable not set")
+	if home := os.Getenv("HOME"); home != "" {
+		legacy := filepath.Join(home, ".ekspodlogs.db")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy, nil
+		}
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
 	}
-	dbFile := fmt.Sprintf("%s/.ekspodlogs.db", homeDir)
-	return dbFile, nil
+	return filepath.Join(dir, "ekspodlogs.db"), nil
 }
 
-// CreateDBIfNotExists creates the database if it does not exist
+// CreateDBIfNotExists creates the database if it does not exist and applies the pending migrations
+// A copy of an existing database is taken before migrating it, see backupPath.
 func CreateDBIfNotExists(dbPath string) (*sqlite.Storage, error) {
-	_, err := os.Stat(dbPath)
-	if os.IsNotExist(err) {
-		s, err := sqlite.NewStorage(dbPath)
-		if err != nil {
-			return nil, err
-		}
-		err = s.Init()
-		if err != nil {
-			return nil, err
-		}
-		if err := s.Close(); err != nil {
-			return nil, fmt.Errorf("failed to close database: %w", err)
-		}
+	ctx := context.Background()
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create the directory of the database: %w", err)
 	}
-
 	s, err := sqlite.NewStorage(dbPath)
 	if err != nil {
 		return nil, err
 	}
+	pending, err := s.PendingMigrations(ctx)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return s, nil
+	}
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	if current == "" {
+		// New database, nothing to backup
+		if err := s.Migrate(io.Discard); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		return s, nil
+	}
+	backup := backupPath(dbPath, current)
+	if err := s.Backup(ctx, backup); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	if err := s.Migrate(io.Discard); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("%w (backup of the database: %s)", err, backup)
+	}
+	fmt.Fprintf(os.Stderr, "Database schema upgraded from version %s to %s (backup: %s)\n", current, pending[len(pending)-1].Version, backup)
 	return s, nil
 }
 
+// backupPath returns the path of the copy of the database taken before migrating it from the version
+func backupPath(dbPath, version string) string {
+	return fmt.Sprintf("%s.backup-%s", dbPath, version)
+}
+
 // InitDB initializes the database
 // It will create the database if it does not exist
 // It will initialize some global variables
 // In case of error, it will exit the program
 func InitDB() {
 	var err error
-	DBPath, err = DefaultDBPath()
+	DBPath, err = currentDBPath()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "unable to get the default DB path: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "unable to get the DB path: %s\n", err.Error())
 		os.Exit(1)
 	}
 	s, err = CreateDBIfNotExists(DBPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "unable to create the sqlite storage: %s", err.Error())
+		fmt.Fprintf(os.Stderr, "unable to create the sqlite storage: %s\n", err.Error())
 		os.Exit(1)
 	}
 }
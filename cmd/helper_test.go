@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+func TestIncrementalPeriod(t *testing.T) {
+	ctx := context.Background()
+	st, err := sqlite.NewStorage(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatalf("err returned by NewStorage(): %v", err.Error())
+	}
+	t.Cleanup(func() { st.Close() })
+	if err := st.Init(); err != nil {
+		t.Fatalf("err returned by Init(): %v", err.Error())
+	}
+	src := sqlite.Source{Profile: "dev", Region: "eu-west-1", AccountID: "123456789012", Loggroup: "group"}
+	filter := sqlite.LogFilter{PodName: "api"}
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	end := begin.Add(2 * time.Hour)
+
+	if _, _, err := IncrementalPeriod(ctx, st, src, filter, time.Time{}, end); err == nil {
+		t.Errorf("IncrementalPeriod() without watermark nor begin date returned no error")
+	}
+	b, e, err := IncrementalPeriod(ctx, st, src, filter, begin, end)
+	if err != nil {
+		t.Fatalf("err returned by IncrementalPeriod(): %v", err.Error())
+	}
+	if !b.StdTime().Equal(begin) || !e.StdTime().Equal(end) {
+		t.Errorf("IncrementalPeriod() = %v..%v, want %v..%v", b.StdTime(), e.StdTime(), begin, end)
+	}
+
+	// The events ingested late, before the watermark, are fetched again
+	watermark := begin.Add(time.Hour)
+	if err := st.SetSyncWatermark(ctx, src, filter, watermark); err != nil {
+		t.Fatalf("err returned by SetSyncWatermark(): %v", err.Error())
+	}
+	b, _, err = IncrementalPeriod(ctx, st, src, filter, begin, end)
+	if err != nil {
+		t.Fatalf("err returned by IncrementalPeriod(): %v", err.Error())
+	}
+	if want := watermark.Add(-incrementalLookback); !b.StdTime().Equal(want) {
+		t.Errorf("IncrementalPeriod() begins at %v, want %v", b.StdTime(), want)
+	}
+}
+
+func TestSyncWatermark(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		lastEvent time.Time
+		end       time.Time
+		want      time.Time
+	}{
+		{"no events", time.Time{}, now.Add(-time.Hour), now.Add(-time.Hour)},
+		{"events before the end", now.Add(-2 * time.Hour), now.Add(-time.Hour), now.Add(-time.Hour)},
+		{"period ending now", now.Add(-2 * time.Hour), now, now.Add(-incrementalLookback)},
+		{"last event after the lookback", now.Add(-time.Second), now, now.Add(-incrementalLookback)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := syncWatermark(tt.lastEvent, tt.end, now); !got.Equal(tt.want) {
+				t.Errorf("syncWatermark() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncrementalPeriodWithoutEvents(t *testing.T) {
+	ctx := context.Background()
+	st, err := sqlite.NewStorage(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatalf("err returned by NewStorage(): %v", err.Error())
+	}
+	t.Cleanup(func() { st.Close() })
+	if err := st.Init(); err != nil {
+		t.Fatalf("err returned by Init(): %v", err.Error())
+	}
+	src := sqlite.Source{Profile: "dev", Loggroup: "group"}
+	filter := sqlite.LogFilter{PodName: "quiet"}
+	lastEvent := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	if err := st.SetSyncWatermark(ctx, src, filter, lastEvent); err != nil {
+		t.Fatalf("err returned by SetSyncWatermark(): %v", err.Error())
+	}
+
+	// A sync without new events moves the watermark to the end of its period
+	now := lastEvent.Add(3 * time.Hour)
+	_, e, err := IncrementalPeriod(ctx, st, src, filter, time.Time{}, now)
+	if err != nil {
+		t.Fatalf("err returned by IncrementalPeriod(): %v", err.Error())
+	}
+	if err := st.SetSyncWatermark(ctx, src, filter, syncWatermark(time.Time{}, e.StdTime(), now)); err != nil {
+		t.Fatalf("err returned by SetSyncWatermark(): %v", err.Error())
+	}
+	b, _, err := IncrementalPeriod(ctx, st, src, filter, time.Time{}, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err returned by IncrementalPeriod(): %v", err.Error())
+	}
+	if want := now.Add(-2 * incrementalLookback); !b.StdTime().Equal(want) {
+		t.Errorf("IncrementalPeriod() after a sync without events begins at %v, want %v", b.StdTime(), want)
+	}
+}
@@ -23,7 +23,7 @@ var listGroupsCmd = &cobra.Command{
 		ctx := context.Background()
 		fmt.Println("profile:", ssoProfile)
 
-		cfg, err = InitAWSConfig(ctx, ssoProfile)
+		cfg, err = InitAWSConfig(ctx, currentAWSOptions(ssoProfile))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "unable to load SDK config: %s", err.Error())
 			os.Exit(1)
@@ -36,7 +36,7 @@ var listGroupsCmd = &cobra.Command{
 		logger := NewLoggerWithDebug(debug)
 		app.SetLogger(logger)
 		
-		if err = app.PrintID(); err != nil {
+		if err = app.PrintID(os.Stdout); err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
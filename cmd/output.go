@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/level"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+// Formats of the output of the req command
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+	outputCSV    = "csv"
+	outputTSV    = "tsv"
+	outputLogfmt = "logfmt"
+	outputRaw    = "raw"
+)
+
+// outputColumns are the columns of the csv, tsv and logfmt formats, in the order of outputRecord.values
+var outputColumns = []string{
+	"event_time", "ingestion_time", "profile", "region", "account_id", "loggroup", "namespace", "pod", "pod_id", "container",
+	"docker_id", "container_image", "host", "stream", "level", "labels", "event_id", "log_stream", "log",
+}
+
+// logWriter writes the logs printed by the req command
+type logWriter interface {
+	// Write writes a log, matches are the byte ranges of the log to highlight
+	Write(r database.Log, matches [][]int) error
+	// Flush writes the logs buffered, it is called after each poll of --follow
+	Flush() error
+	// Close writes the end of the output
+	Close() error
+}
+
+// newLogWriter returns the writer of the logs in the given format
+// newFormatTime returns a formatter of the times, each time column has its own as the delta format
+// depends on the previous time of the column.
+func newLogWriter(w io.Writer, format string, newFormatTime func() func(time.Time) string, showContainer bool, noColor bool) (logWriter, error) {
+	switch strings.ToLower(format) {
+	case "", outputText:
+		return &textWriter{w: w, formatTime: newFormatTime(), showContainer: showContainer, noColor: noColor}, nil
+	case outputJSON:
+		return &jsonWriter{w: bufio.NewWriter(w), array: true}, nil
+	case outputNDJSON:
+		return &jsonWriter{w: bufio.NewWriter(w)}, nil
+	case outputCSV:
+		return &csvWriter{w: csv.NewWriter(w), times: newColumnTimes(newFormatTime)}, nil
+	case outputTSV:
+		return &tsvWriter{w: bufio.NewWriter(w), times: newColumnTimes(newFormatTime)}, nil
+	case outputLogfmt:
+		return &logfmtWriter{w: bufio.NewWriter(w), times: newColumnTimes(newFormatTime)}, nil
+	case outputRaw:
+		return &rawWriter{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (expected text, json, ndjson, csv, tsv, logfmt or raw)", format)
+}
+
+// isTerminal returns true if f is a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// outputRecord is a log as printed by the json, ndjson, csv, tsv and logfmt formats
+type outputRecord struct {
+	EventTime      time.Time         `json:"event_time"`
+	IngestionTime  *time.Time        `json:"ingestion_time,omitempty"`
+	Profile        string            `json:"profile"`
+	Region         string            `json:"region,omitempty"`
+	AccountID      string            `json:"account_id,omitempty"`
+	Loggroup       string            `json:"loggroup"`
+	Namespace      string            `json:"namespace"`
+	Pod            string            `json:"pod"`
+	PodID          string            `json:"pod_id,omitempty"`
+	Container      string            `json:"container"`
+	DockerID       string            `json:"docker_id,omitempty"`
+	ContainerImage string            `json:"container_image,omitempty"`
+	Host           string            `json:"host,omitempty"`
+	Stream         string            `json:"stream,omitempty"`
+	Level          string            `json:"level,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	EventID        string            `json:"event_id,omitempty"`
+	LogStream      string            `json:"log_stream,omitempty"`
+	Log            string            `json:"log"`
+}
+
+func newOutputRecord(r database.Log) outputRecord {
+	o := outputRecord{
+		EventTime:      r.EventTime,
+		Profile:        r.Profile,
+		Region:         r.Region,
+		AccountID:      r.AccountID,
+		Loggroup:       r.Loggroup,
+		Namespace:      r.NamespaceName,
+		Pod:            r.PodName,
+		PodID:          r.PodID,
+		Container:      r.ContainerName,
+		DockerID:       r.DockerID,
+		ContainerImage: r.ContainerImage,
+		Host:           r.Host,
+		Stream:         r.Stream,
+		Level:          r.Level,
+		Labels:         sqlite.DecodeLabels(r.Labels),
+		EventID:        r.EventID,
+		LogStream:      r.LogStreamName,
+		Log:            strings.TrimSpace(r.Log),
+	}
+	if r.IngestionTime.Valid {
+		o.IngestionTime = &r.IngestionTime.Time
+	}
+	return o
+}
+
+// columnTimes formats the times of the event_time and ingestion_time columns
+type columnTimes struct {
+	eventTime     func(time.Time) string
+	ingestionTime func(time.Time) string
+}
+
+func newColumnTimes(newFormatTime func() func(time.Time) string) columnTimes {
+	return columnTimes{eventTime: newFormatTime(), ingestionTime: newFormatTime()}
+}
+
+// values returns the fields of the record in the order of outputColumns
+func (o outputRecord) values(times columnTimes) []string {
+	var ingestionTime string
+	if o.IngestionTime != nil {
+		ingestionTime = times.ingestionTime(*o.IngestionTime)
+	}
+	var labels string
+	if len(o.Labels) > 0 {
+		b, _ := json.Marshal(o.Labels)
+		labels = string(b)
+	}
+	return []string{
+		times.eventTime(o.EventTime), ingestionTime, o.Profile, o.Region, o.AccountID, o.Loggroup, o.Namespace, o.Pod, o.PodID, o.Container,
+		o.DockerID, o.ContainerImage, o.Host, o.Stream, o.Level, labels, o.EventID, o.LogStream, o.Log,
+	}
+}
+
+// textWriter writes the logs for a human, with a header line and colors
+type textWriter struct {
+	w             io.Writer
+	formatTime    func(time.Time) string
+	showContainer bool
+	noColor       bool
+	started       bool
+}
+
+func (t *textWriter) Write(r database.Log, matches [][]int) error {
+	if !t.started {
+		t.started = true
+		header := "Event Time\tLog"
+		if t.showContainer {
+			header = "Event Time\tContainer Name\tLog"
+		}
+		if _, err := fmt.Fprintln(t.w, header); err != nil {
+			return err
+		}
+	}
+	logText, matches := trimLog(r.Log, matches)
+	colorizedLog := colorizeLog(logText, level.Level(r.Level), matches, t.noColor)
+	var err error
+	if t.showContainer {
+		_, err = fmt.Fprintf(t.w, "%s\t%s\t%s\n", t.formatTime(r.EventTime), strings.TrimSpace(r.ContainerName), colorizedLog)
+	} else {
+		_, err = fmt.Fprintf(t.w, "%s\t%s\n", t.formatTime(r.EventTime), colorizedLog)
+	}
+	return err
+}
+
+func (t *textWriter) Flush() error {
+	return nil
+}
+
+func (t *textWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes the logs as a JSON array, or one JSON object per line
+type jsonWriter struct {
+	w     *bufio.Writer
+	array bool
+	count int
+}
+
+func (j *jsonWriter) Write(r database.Log, _ [][]int) error {
+	b, err := json.Marshal(newOutputRecord(r))
+	if err != nil {
+		return fmt.Errorf("failed to encode log: %w", err)
+	}
+	if j.array {
+		sep := ",\n  "
+		if j.count == 0 {
+			sep = "[\n  "
+		}
+		if _, err := j.w.WriteString(sep); err != nil {
+			return err
+		}
+	}
+	j.count++
+	if _, err := j.w.Write(b); err != nil {
+		return err
+	}
+	if !j.array {
+		return j.w.WriteByte('\n')
+	}
+	return nil
+}
+
+func (j *jsonWriter) Flush() error {
+	return j.w.Flush()
+}
+
+func (j *jsonWriter) Close() error {
+	if j.array {
+		end := "\n]\n"
+		if j.count == 0 {
+			end = "[]\n"
+		}
+		if _, err := j.w.WriteString(end); err != nil {
+			return err
+		}
+	}
+	return j.w.Flush()
+}
+
+// csvWriter writes the logs as CSV with a header line
+type csvWriter struct {
+	w       *csv.Writer
+	times   columnTimes
+	started bool
+}
+
+func (c *csvWriter) Write(r database.Log, _ [][]int) error {
+	if !c.started {
+		c.started = true
+		if err := c.w.Write(outputColumns); err != nil {
+			return err
+		}
+	}
+	return c.w.Write(newOutputRecord(r).values(c.times))
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	return c.Flush()
+}
+
+// tsvReplacer escapes the characters of the values that would break the TSV layout
+var tsvReplacer = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// tsvWriter writes the logs as tab separated values with a header line
+type tsvWriter struct {
+	w       *bufio.Writer
+	times   columnTimes
+	started bool
+}
+
+func (t *tsvWriter) Write(r database.Log, _ [][]int) error {
+	if !t.started {
+		t.started = true
+		if _, err := t.w.WriteString(strings.Join(outputColumns, "\t") + "\n"); err != nil {
+			return err
+		}
+	}
+	values := newOutputRecord(r).values(t.times)
+	for i, v := range values {
+		values[i] = tsvReplacer.Replace(v)
+	}
+	_, err := t.w.WriteString(strings.Join(values, "\t") + "\n")
+	return err
+}
+
+func (t *tsvWriter) Flush() error {
+	return t.w.Flush()
+}
+
+func (t *tsvWriter) Close() error {
+	return t.w.Flush()
+}
+
+// logfmtWriter writes the logs as key=value pairs, the empty values are omitted
+type logfmtWriter struct {
+	w     *bufio.Writer
+	times columnTimes
+}
+
+func (l *logfmtWriter) Write(r database.Log, _ [][]int) error {
+	var pairs []string
+	for i, v := range newOutputRecord(r).values(l.times) {
+		if v == "" && outputColumns[i] != "log" {
+			continue
+		}
+		pairs = append(pairs, outputColumns[i]+"="+logfmtValue(v))
+	}
+	_, err := l.w.WriteString(strings.Join(pairs, " ") + "\n")
+	return err
+}
+
+func (l *logfmtWriter) Flush() error {
+	return l.w.Flush()
+}
+
+func (l *logfmtWriter) Close() error {
+	return l.w.Flush()
+}
+
+// logfmtValue quotes the value if it contains spaces, quotes, equal signs or control characters
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsFunc(v, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '=' || r == '\\' || r == 0x7f
+	}) {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// rawWriter writes only the log messages
+type rawWriter struct {
+	w *bufio.Writer
+}
+
+func (rw *rawWriter) Write(r database.Log, _ [][]int) error {
+	_, err := rw.w.WriteString(strings.TrimSpace(r.Log) + "\n")
+	return err
+}
+
+func (rw *rawWriter) Flush() error {
+	return rw.w.Flush()
+}
+
+func (rw *rawWriter) Close() error {
+	return rw.w.Flush()
+}
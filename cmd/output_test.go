@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/internal/database"
+)
+
+// outputLogs are the logs written by the tests of the output formats
+func outputLogs() []database.Log {
+	t := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	return []database.Log{
+		{
+			EventTime: t, IngestionTime: sql.NullTime{Time: t.Add(2 * time.Second), Valid: true},
+			Profile: "dev", Loggroup: "grp", NamespaceName: "default", PodName: "api-1", ContainerName: "api",
+			Labels: `{"app":"api"}`, Level: "info", EventID: "1", Log: "GET /health 200\n",
+		},
+		{
+			EventTime: t.Add(1500 * time.Millisecond), IngestionTime: sql.NullTime{Time: t.Add(2500 * time.Millisecond), Valid: true},
+			Profile: "dev", Loggroup: "grp", NamespaceName: "default", PodName: "api-1", ContainerName: "api",
+			Stream: "stderr", Labels: "{}", EventID: "2", Log: "a=\"b, c\"\tx\\y\nz",
+		},
+	}
+}
+
+func TestLogWriters(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{outputCSV, `event_time,ingestion_time,profile,region,account_id,loggroup,namespace,pod,pod_id,container,docker_id,container_image,host,stream,level,labels,event_id,log_stream,log
+2025-03-01 10:00:00.000,2025-03-01 10:00:02.000,dev,,,grp,default,api-1,,api,,,,,info,"{""app"":""api""}",1,,GET /health 200
+2025-03-01 10:00:01.500,2025-03-01 10:00:02.500,dev,,,grp,default,api-1,,api,,,,stderr,,,2,,"a=""b, c""	x\y
+z"
+`},
+		{outputTSV, "event_time\tingestion_time\tprofile\tregion\taccount_id\tloggroup\tnamespace\tpod\tpod_id\tcontainer\tdocker_id\tcontainer_image\thost\tstream\tlevel\tlabels\tevent_id\tlog_stream\tlog\n" +
+			"2025-03-01 10:00:00.000\t2025-03-01 10:00:02.000\tdev\t\t\tgrp\tdefault\tapi-1\t\tapi\t\t\t\t\tinfo\t{\"app\":\"api\"}\t1\t\tGET /health 200\n" +
+			"2025-03-01 10:00:01.500\t2025-03-01 10:00:02.500\tdev\t\t\tgrp\tdefault\tapi-1\t\tapi\t\t\t\tstderr\t\t\t2\t\ta=\"b, c\"\\tx\\\\y\\nz\n"},
+		{outputNDJSON, `{"event_time":"2025-03-01T10:00:00Z","ingestion_time":"2025-03-01T10:00:02Z","profile":"dev","loggroup":"grp","namespace":"default","pod":"api-1","container":"api","level":"info","labels":{"app":"api"},"event_id":"1","log":"GET /health 200"}
+{"event_time":"2025-03-01T10:00:01.5Z","ingestion_time":"2025-03-01T10:00:02.5Z","profile":"dev","loggroup":"grp","namespace":"default","pod":"api-1","container":"api","stream":"stderr","event_id":"2","log":"a=\"b, c\"\tx\\y\nz"}
+`},
+		{outputLogfmt, `event_time="2025-03-01 10:00:00.000" ingestion_time="2025-03-01 10:00:02.000" profile=dev loggroup=grp namespace=default pod=api-1 container=api level=info labels="{\"app\":\"api\"}" event_id=1 log="GET /health 200"
+event_time="2025-03-01 10:00:01.500" ingestion_time="2025-03-01 10:00:02.500" profile=dev loggroup=grp namespace=default pod=api-1 container=api stream=stderr event_id=2 log="a=\"b, c\"\tx\\y\nz"
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			newFormatTime := func() func(time.Time) string { return newTimeFormatter("default", time.Now(), time.UTC) }
+			out, err := newLogWriter(&buf, tt.format, newFormatTime, false, true)
+			if err != nil {
+				t.Fatalf("err returned by newLogWriter(): %v", err.Error())
+			}
+			for _, l := range outputLogs() {
+				if err := out.Write(l, nil); err != nil {
+					t.Fatalf("err returned by Write(): %v", err.Error())
+				}
+			}
+			if err := out.Close(); err != nil {
+				t.Fatalf("err returned by Close(): %v", err.Error())
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLogWritersDelta(t *testing.T) {
+	// Each time column has its own previous time
+	var buf bytes.Buffer
+	newFormatTime := func() func(time.Time) string { return newTimeFormatter("delta", time.Now(), time.UTC) }
+	out, err := newLogWriter(&buf, outputLogfmt, newFormatTime, false, true)
+	if err != nil {
+		t.Fatalf("err returned by newLogWriter(): %v", err.Error())
+	}
+	for _, l := range outputLogs() {
+		if err := out.Write(l, nil); err != nil {
+			t.Fatalf("err returned by Write(): %v", err.Error())
+		}
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("err returned by Close(): %v", err.Error())
+	}
+	lines := bytes.Split(buf.Bytes(), []byte("\n"))
+	want := "event_time=+1.5s ingestion_time=+500ms "
+	if !bytes.HasPrefix(lines[1], []byte(want)) {
+		t.Errorf("second line = %s, want the prefix %s", lines[1], want)
+	}
+}
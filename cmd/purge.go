@@ -48,8 +48,9 @@ Try option -h to see option in order to to purge only specific logs.`,
 			}
 		}()
 
-		if groupName != "" || podName != "" || ssoProfile != "" {
-			err = s.PurgeSpecificLogPodLogs(ctx, ssoProfile, groupName, podName)
+		filter := currentLogFilter()
+		if groupName != "" || ssoProfile != "" || !filter.IsEmpty() {
+			err = s.PurgeSpecificLogPodLogs(ctx, ssoProfile, groupName, filter)
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err.Error())
 				os.Exit(1)
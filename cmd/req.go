@@ -2,41 +2,112 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"regexp"
+	"sort"
 	"strings"
 	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/gookit/color"
 	"github.com/sgaunet/ekspodlogs/internal/app"
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/coverage"
+	"github.com/sgaunet/ekspodlogs/pkg/level"
+	"github.com/sgaunet/ekspodlogs/pkg/parser"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
 	"github.com/sgaunet/ekspodlogs/pkg/views"
 	"github.com/spf13/cobra"
 )
 
-// colorizeLog applies color to log messages based on log level patterns
-func colorizeLog(logText string, noColor bool) string {
+// levelStyle returns the style of a log message based on its level
+func levelStyle(l level.Level) color.Style {
+	switch l {
+	case level.Error, level.Fatal:
+		return color.Style{color.Red}
+	case level.Warn:
+		return color.Style{color.Yellow}
+	case level.Info:
+		return color.Style{color.Blue}
+	default:
+		return color.Style{}
+	}
+}
+
+// colorizeLog applies color to log messages based on their level
+// and highlights the byte ranges given by matches
+func colorizeLog(logText string, l level.Level, matches [][]int, noColor bool) string {
 	if noColor {
 		return logText
 	}
 
-	// Case-insensitive regex patterns for different log levels
-	infoPattern := regexp.MustCompile(`(?i)\b(info|information)\b|\[info\]|info:|level=info|"level":"info"`)
-	warnPattern := regexp.MustCompile(`(?i)\b(warn|warning)\b|\[warn\]|\[warning\]|warn:|warning:|level=warn|level=warning|"level":"warn"|"level":"warning"`)
-	errorPattern := regexp.MustCompile(`(?i)\b(error|err|fatal|critical)\b|\[error\]|\[err\]|\[fatal\]|\[critical\]|error:|err:|fatal:|critical:|level=error|level=err|level=fatal|level=critical|"level":"error"|"level":"err"|"level":"fatal"|"level":"critical"`)
-
-	switch {
-	case errorPattern.MatchString(logText):
-		return color.Red.Sprint(logText)
-	case warnPattern.MatchString(logText):
-		return color.Yellow.Sprint(logText)
-	case infoPattern.MatchString(logText):
-		return color.Blue.Sprint(logText)
-	default:
-		return logText
+	style := levelStyle(l)
+	highlight := append(color.Style{color.OpBold, color.OpUnderscore}, style...)
+	sprint := func(st color.Style, text string) string {
+		if len(st) == 0 || text == "" {
+			return text
+		}
+		return st.Sprint(text)
 	}
+
+	var sb strings.Builder
+	pos := 0
+	for _, m := range matches {
+		start, end := max(m[0], pos), min(m[1], len(logText))
+		if start >= end {
+			continue
+		}
+		sb.WriteString(sprint(style, logText[pos:start]))
+		sb.WriteString(sprint(highlight, logText[start:end]))
+		pos = end
+	}
+	sb.WriteString(sprint(style, logText[pos:]))
+	return sb.String()
+}
+
+// trimLog removes the leading and trailing spaces of a log message and shifts the matches accordingly
+func trimLog(logText string, matches [][]int) (string, [][]int) {
+	left := len(logText) - len(strings.TrimLeftFunc(logText, unicode.IsSpace))
+	trimmed := strings.TrimSpace(logText)
+	shifted := make([][]int, 0, len(matches))
+	for _, m := range matches {
+		shifted = append(shifted, []int{m[0] - left, m[1] - left})
+	}
+	return trimmed, shifted
+}
+
+// iterateOptions returns the range of logs selected by --limit, --offset, --head, --tail and --reverse
+// fromEnd is true for --tail without --reverse: the logs are read from the end and have to be printed back in order.
+func iterateOptions() (opts sqlite.IterateOptions, fromEnd bool, err error) {
+	if limitLogs < 0 || offsetLogs < 0 || headLogs < 0 || tailLogs < 0 {
+		return opts, false, errors.New("--limit, --offset, --head and --tail can not be negative")
+	}
+	if headLogs > 0 && tailLogs > 0 {
+		return opts, false, errors.New("--head and --tail can not be combined")
+	}
+	if limitLogs > 0 && (headLogs > 0 || tailLogs > 0) {
+		return opts, false, errors.New("--limit can not be combined with --head or --tail")
+	}
+	// The results of a search are ordered by relevance, they have no end to read from
+	if search != "" && (tailLogs > 0 || reverseOrder) {
+		return opts, false, errors.New("--search can not be combined with --tail or --reverse, use --head or --limit")
+	}
+	opts = sqlite.IterateOptions{Limit: limitLogs, Offset: offsetLogs, Reverse: reverseOrder}
+	if headLogs > 0 {
+		opts.Limit = headLogs
+	}
+	if tailLogs > 0 {
+		// The last logs are read from the end, they are already in the order of --reverse
+		opts.Limit = tailLogs
+		opts.Reverse = true
+		return opts, !reverseOrder, nil
+	}
+	return opts, false, nil
 }
 
 // reqCmd represents the req command
@@ -49,19 +120,101 @@ var reqCmd = &cobra.Command{
 		var err error
 		ctx := context.Background()
 
-		if beginDate == "" || endDate == "" {
-			fmt.Fprintln(os.Stderr, "Mandatory options : -b and -e")
+		begin, end, err := resolvePeriod(time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if follow {
+			if err := checkFollowOptions(); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		}
+		targets, err := currentTargets()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if follow && len(targets) > 1 {
+			fmt.Fprintln(os.Stderr, "--follow can not be combined with several profiles, regions or log groups")
+			os.Exit(1)
+		}
+		// Without a period, --follow only prints the new logs
+		followOnly := follow && begin.IsZero()
+		if followOnly {
+			begin = time.Now()
+		}
+		if begin.IsZero() {
+			fmt.Fprintln(os.Stderr, "Mandatory option : -b, --since, --last or --around")
 			if err := cmd.Help(); err != nil {
 				fmt.Fprintf(os.Stderr, "Error displaying help: %v\n", err)
 			}
 			os.Exit(1)
 		}
-
-		b, e, err := ConvertTimeToCarbon(beginDate, endDate)
+		if end.IsZero() {
+			end = time.Now()
+		}
+		b, e, err := ConvertTimeToCarbon(begin, end)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		loc, err := loadLocation(timezone)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
+		if err := checkLabelFilters(labelFilters); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		filter := currentLogFilter()
+		if minLevel != "" {
+			if filter.Level, err = level.Parse(minLevel); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		}
+		if err := filter.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		opts, fromEnd, err := iterateOptions()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		// Colors are only written for a human reading the text format in a terminal
+		textOutput := strings.ToLower(outputFormat) == outputText
+		colored := !noColor && textOutput && isTerminal(os.Stdout)
+		now := time.Now()
+		newFormatTime := func() func(time.Time) string { return newTimeFormatter(timeFormat, now, loc) }
+		out, err := newLogWriter(os.Stdout, outputFormat, newFormatTime, containerName, !colored)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if templateText != "" {
+			if !textOutput {
+				fmt.Fprintln(os.Stderr, "--template can not be combined with --output")
+				os.Exit(1)
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			text, err := resolveTemplate(templateText, cfg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			if out, err = newTemplateWriter(os.Stdout, text, newFormatTime(), loc, !colored); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		}
 
 		InitDB() // Initialize the database and exit if an error occurs
 
@@ -91,24 +244,42 @@ var reqCmd = &cobra.Command{
 			}
 		}()
 
-		cfg, err = InitAWSConfig(ctx, ssoProfile)
+		// Several targets are only requested in the database, AWS is not called
+		single := len(targets) == 1
+		if single {
+			ssoProfile, groupName = targets[0].aws.Profile, targets[0].group
+			cfg, err = InitAWSConfig(ctx, targets[0].aws)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "unable to load SDK config: %s", err.Error())
+				os.Exit(1)
+			}
+		}
+		// Filter the events fetched by --follow in CloudWatch, as sync does
+		logParser, err := parser.ByName(parserName)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "unable to load SDK config: %s", err.Error())
+			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
+		var pattern string
+		if logParser.Name() != parser.RawName {
+			pattern = app.FilterPattern(filter)
+		}
+
 		tui := views.NewTerminalView()
 		app := app.New(cfg, ssoProfile, s, tui)
 		
 		// Configure logger based on debug flag
 		logger := NewLoggerWithDebug(debug)
 		app.SetLogger(logger)
+		app.SetParser(logParser)
+		app.SetFilterPattern(pattern)
 		
-		// if err = app.PrintID(); err != nil {
+		// if err = app.PrintID(os.Stdout); err != nil {
 		// 	fmt.Fprintln(os.Stderr, err.Error())
 		// 	os.Exit(1)
 		// }
 
-		if groupName == "" {
+		if single && groupName == "" {
 			// No groupName specified, try to find it automatically
 			groupName, err = app.FindLogGroupAuto(ctx)
 			if groupName == "" {
@@ -119,39 +290,106 @@ var reqCmd = &cobra.Command{
 				fmt.Fprintf(os.Stderr, "%v\n", err.Error())
 				os.Exit(1)
 			}
+			targets[0].group = groupName
+		}
+
+		for _, t := range targets {
+			if t.group == "" {
+				continue // all the log groups of the profile are requested
+			}
+			// The account is not known without calling AWS, the windows of all the accounts are selected
+			src := sqlite.Source{Profile: t.aws.Profile, Region: t.aws.Region, Loggroup: t.group}
+			covered, err := s.GetCoverage(ctx, src, filter)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			if gaps := coverage.Gaps(covered, b.StdTime(), e.StdTime()); len(gaps) > 0 && !followOnly {
+				if !single {
+					fmt.Fprintf(os.Stderr, "%s:\n", t)
+				}
+				printGaps(os.Stderr, gaps, loc)
+			}
 		}
+		selection := storageTargets(targets)
 
-		res, err := app.GetEvents(ctx, ssoProfile, groupName, podName, b, e)
+		// The logs saved from now on are printed by --follow
+		lastID, err := s.LastLogID(ctx)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
-		
-		if len(res) == 0 {
-			fmt.Println("No logs found for the specified criteria")
-			return
-		}
-
-		if containerName {
-			fmt.Println("Event Time\tContainer Name\tLog")
-			for _, r := range res {
-				logText := strings.TrimSpace(r.Log)
-				colorizedLog := colorizeLog(logText, noColor)
-				fmt.Printf("%s\t%s\t%s\n",
-					r.EventTime.Format("2006-01-02 15:04:05"),
-					strings.TrimSpace(r.ContainerName),
-					colorizedLog,
-				)
+
+		var grepRe *regexp.Regexp
+		if len(grepPatterns) > 0 {
+			grepRe = regexp.MustCompile(sqlite.JoinRegexps(grepPatterns))
+		}
+		type result struct {
+			log     database.Log
+			matches [][]int
+		}
+		count := 0
+		lastTime := b.StdTime() // time of the last log printed
+		var tailBuffer []result // logs read from the end by --tail
+		write := func(l database.Log, matches [][]int) error {
+			count++
+			if l.EventTime.After(lastTime) {
+				lastTime = l.EventTime
+			}
+			// Highlight the text matching --grep along with the terms found by --search
+			if grepRe != nil {
+				matches = append(matches, grepRe.FindAllStringIndex(l.Log, -1)...)
+				sort.Slice(matches, func(a, b int) bool {
+					return matches[a][0] < matches[b][0]
+				})
+			}
+			if fromEnd {
+				tailBuffer = append(tailBuffer, result{log: l, matches: matches})
+				return nil
 			}
+			return out.Write(l, matches)
+		}
+		if search != "" {
+			err = app.IterateSearchEvents(ctx, selection, filter, search, b, e, opts, func(r sqlite.SearchResult) error {
+				return write(r.Log, r.Matches)
+			})
 		} else {
-			fmt.Println("Event Time\tLog")
-			for _, r := range res {
-				logText := strings.TrimSpace(r.Log)
-				colorizedLog := colorizeLog(logText, noColor)
-				fmt.Printf("%s\t%s\n",
-					r.EventTime.Format("2006-01-02 15:04:05"),
-					colorizedLog,
-				)
+			err = app.IterateEvents(ctx, selection, filter, b, e, opts, func(l database.Log) error {
+				return write(l, nil)
+			})
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		for i := len(tailBuffer) - 1; i >= 0; i-- {
+			if err := out.Write(tailBuffer[i].log, tailBuffer[i].matches); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to write log: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if follow {
+			fromEnd = false
+			if err := out.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to write logs: %v\n", err)
+				os.Exit(1)
+			}
+			// Fetch the events from the last log printed, the database may not be synchronised until now
+			if err := followLogs(ctx, app, groupName, selection, filter, lastTime, lastID, write, out); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		}
+		if err := out.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write logs: %v\n", err)
+			os.Exit(1)
+		}
+		if count == 0 && !follow {
+			// Keep the output of the machine readable formats parsable
+			if textOutput {
+				fmt.Println("No logs found for the specified criteria")
+			} else {
+				fmt.Fprintln(os.Stderr, "No logs found for the specified criteria")
 			}
 		}
 	},
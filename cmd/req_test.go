@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+func TestIterateOptions(t *testing.T) {
+	defer func() { headLogs, tailLogs, limitLogs, offsetLogs, reverseOrder, search = 0, 0, 0, 0, false, "" }()
+
+	// 10 logs, one per minute from 10:00
+	ctx := context.Background()
+	st, err := sqlite.NewStorage(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatalf("err returned by NewStorage(): %v", err.Error())
+	}
+	t.Cleanup(func() { st.Close() })
+	if err := st.Init(); err != nil {
+		t.Fatalf("err returned by Init(): %v", err.Error())
+	}
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	var records []sqlite.LogRecord
+	for i := range 10 {
+		records = append(records, sqlite.LogRecord{EventID: strconv.Itoa(i), EventTime: begin.Add(time.Duration(i) * time.Minute), Log: strconv.Itoa(i)})
+	}
+	if err := st.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+
+	tests := []struct {
+		name              string
+		head, tail, limit int
+		offset            int
+		reverse           bool
+		want              []string
+	}{
+		{"all", 0, 0, 0, 0, false, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}},
+		{"head", 3, 0, 0, 0, false, []string{"0", "1", "2"}},
+		{"head reverse", 3, 0, 0, 0, true, []string{"9", "8", "7"}},
+		{"tail", 0, 3, 0, 0, false, []string{"7", "8", "9"}},
+		{"tail reverse", 0, 3, 0, 0, true, []string{"9", "8", "7"}},
+		{"limit offset", 0, 0, 2, 4, false, []string{"4", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headLogs, tailLogs, limitLogs, offsetLogs, reverseOrder = tt.head, tt.tail, tt.limit, tt.offset, tt.reverse
+			opts, fromEnd, err := iterateOptions()
+			if err != nil {
+				t.Fatalf("err returned by iterateOptions(): %v", err.Error())
+			}
+			var logs []string
+			err = st.IterateLogs(ctx, sqlite.Target("dev", "group"), sqlite.LogFilter{}, carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour)), opts, func(l database.Log) error {
+				logs = append(logs, l.Log)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("err returned by IterateLogs(): %v", err.Error())
+			}
+			// req prints back in order the logs read from the end
+			if fromEnd {
+				slices.Reverse(logs)
+			}
+			if !slices.Equal(logs, tt.want) {
+				t.Errorf("printed logs = %v, want %v", logs, tt.want)
+			}
+		})
+	}
+
+	for _, invalid := range [][2]int{{3, 3}, {-1, 0}} {
+		headLogs, tailLogs, limitLogs, offsetLogs, reverseOrder = invalid[0], invalid[1], 0, 0, false
+		if _, _, err := iterateOptions(); err == nil {
+			t.Errorf("iterateOptions() with --head %d --tail %d returned no error", invalid[0], invalid[1])
+		}
+	}
+
+	headLogs, tailLogs, search = 0, 3, "error"
+	if _, _, err := iterateOptions(); err == nil || !strings.Contains(err.Error(), "--tail") {
+		t.Errorf("iterateOptions() with --search --tail returned the error %v, want an error about --tail", err)
+	}
+	headLogs, tailLogs = 3, 0
+	if _, _, err := iterateOptions(); err != nil {
+		t.Errorf("iterateOptions() with --search --head returned the error %v", err)
+	}
+}
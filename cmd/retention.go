@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sgaunet/ekspodlogs/internal/config"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+// sizePattern matches the sizes of the retention: 500MB, 2GB, 1.5GiB, 1000000
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([A-Z]*)$`)
+
+// sizeUnits are the units of the sizes, in powers of 1000 or of 1024
+var sizeUnits = map[string]float64{
+	"": 1, "B": 1,
+	"K": 1e3, "KB": 1e3, "M": 1e6, "MB": 1e6, "G": 1e9, "GB": 1e9, "T": 1e12, "TB": 1e12,
+	"KIB": 1 << 10, "MIB": 1 << 20, "GIB": 1 << 30, "TIB": 1 << 40,
+}
+
+// parseSize parses a size in bytes, with the units KB, MB, GB, TB or KiB, MiB, GiB, TiB
+func parseSize(s string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q (e.g. 500MB, 2GB)", s)
+	}
+	unit, ok := sizeUnits[m[2]]
+	if !ok {
+		return 0, fmt.Errorf("invalid unit in size %q (e.g. 500MB, 2GB)", s)
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	return int64(v * unit), nil
+}
+
+// formatSize formats a size in bytes for a human
+func formatSize(size int64) string {
+	v := float64(size)
+	for _, u := range []string{"B", "KB", "MB", "GB"} {
+		if v < 1000 {
+			return fmt.Sprintf("%.4g %s", v, u)
+		}
+		v /= 1000
+	}
+	return fmt.Sprintf("%.4g TB", v)
+}
+
+// retentionPolicy converts a retention policy of the config file
+func retentionPolicy(p config.RetentionPolicy) (sqlite.RetentionPolicy, error) {
+	policy := sqlite.RetentionPolicy{MaxRows: p.MaxRows}
+	if p.MaxAge != "" {
+		d, err := parseDuration(p.MaxAge)
+		if err != nil {
+			return policy, fmt.Errorf("invalid max age of the retention: %w", err)
+		}
+		policy.MaxAge = d
+	}
+	return policy, nil
+}
+
+// currentRetention returns the retention of the config file, the default policy and the size
+// are overridden by --max-age, --max-rows and --max-size
+func currentRetention(cfg *config.Config) (sqlite.Retention, error) {
+	defaults := cfg.Retention.RetentionPolicy
+	if maxAge != "" {
+		defaults.MaxAge = maxAge
+	}
+	if maxRows > 0 {
+		defaults.MaxRows = maxRows
+	}
+	var r sqlite.Retention
+	var err error
+	if r.Default, err = retentionPolicy(defaults); err != nil {
+		return r, err
+	}
+	if len(cfg.Retention.Loggroups) > 0 {
+		r.Loggroups = make(map[string]sqlite.RetentionPolicy, len(cfg.Retention.Loggroups))
+		for loggroup, p := range cfg.Retention.Loggroups {
+			if r.Loggroups[loggroup], err = retentionPolicy(p); err != nil {
+				return r, fmt.Errorf("loggroup %s: %w", loggroup, err)
+			}
+		}
+	}
+	size := cfg.Retention.MaxSize
+	if maxSize != "" {
+		size = maxSize
+	}
+	if size != "" {
+		if r.MaxSize, err = parseSize(size); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// applyRetention removes the logs exceeding the retention and prints the number of logs removed
+func applyRetention(ctx context.Context, r sqlite.Retention) error {
+	deleted, err := s.ApplyRetention(ctx, r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d logs removed by the retention\n", deleted)
+	return nil
+}
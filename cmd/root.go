@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
 	"github.com/spf13/cobra"
@@ -12,15 +13,77 @@ var (
 	DBPath string
 	s      *sqlite.Storage
 
+	// Set by the context
+	contextDB string
+
+	// Targets of sync and req
+	profileNames []string
+	regionNames  []string
+	groupNames   []string
+	contextNames []string
+
+	// Connection to AWS
+	awsRegion       string
+	roleARNs        []string
+	externalID      string
+	roleSessionName string
+	mfaSerial       string
+
 	// Flags
-	beginDate     string
-	endDate       string
-	groupName     string
-	ssoProfile    string
-	podName       string
-	debug         bool
-	containerName bool
-	noColor       bool
+	beginDate      string
+	endDate        string
+	groupName      string
+	ssoProfile     string
+	podName        string
+	debug          bool
+	containerName  bool
+	noColor        bool
+	incremental    bool
+	workers        int
+	timeFormat     string
+	filterPattern  string
+	parserName     string
+	outputFormat   string
+	templateText   string
+	configFile     string
+	dbFile         string
+	workspace      string
+	contextName    string
+	limitLogs      int
+	offsetLogs     int
+	headLogs       int
+	tailLogs       int
+	reverseOrder   bool
+	follow         bool
+	followInterval time.Duration
+	daemonInterval string
+	daemonKeep     string
+	pidFile        string
+	maxAge         string
+	maxRows        int64
+	maxSize        string
+	withRetention  bool
+
+	// Period
+	sinceDate    string
+	lastDuration string
+	untilDate    string
+	aroundDate   string
+	window       string
+	timezone     string
+
+	// Filters on the pods
+	namespaceFilter    string
+	containerFilter    string
+	excludedContainers []string
+	labelFilters       []string
+	imageFilter        string
+	nodeFilter         string
+	stderrOnly         bool
+	search             string
+	grepPatterns       []string
+	grepVPatterns      []string
+	minLevel           string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -33,6 +96,12 @@ First, you need to configure your AWS credentials with the AWS CLI.
 Then, you will have to synchronise the local database with the logs of cloudwatch for a period.
 
 Finally, you will be able to request the logs of a specific logstream for a period.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := applyContext(cmd); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := cmd.Help(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error displaying help: %v\n", err)
@@ -49,33 +118,128 @@ func Execute() {
 }
 
 func init() {
-	syncCmd.Flags().StringVarP(&beginDate, "begin", "b", "", "Begin date")
-	syncCmd.Flags().StringVarP(&endDate, "end", "e", "", "End date")
-	syncCmd.Flags().StringVarP(&groupName, "group", "g", "", "Group name (not mandatory if there is only one log group : /aws/containerinsights/<Name of your cluster>/application)")
-	syncCmd.Flags().StringVarP(&ssoProfile, "profile", "p", "", "SSO profile (not mandatory)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Configuration file (default: ~/.config/ekspodlogs/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&dbFile, "db", "", "Database file (default: $EKSPODLOGS_DB, ~/.ekspodlogs.db if it exists, or ~/.local/share/ekspodlogs/ekspodlogs.db)")
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "Context of the config file (default: $EKSPODLOGS_CONTEXT or the current context)")
+	rootCmd.PersistentFlags().StringVar(&workspace, "workspace", "", "Named workspace with its own database (~/.local/share/ekspodlogs/workspaces/<name>.db)")
+
+	addPeriodFlags(syncCmd)
+	syncCmd.Flags().StringSliceVarP(&groupNames, "group", "g", nil, "Group name (not mandatory if there is only one log group : /aws/containerinsights/<Name of your cluster>/application), can be repeated")
+	syncCmd.Flags().StringSliceVarP(&profileNames, "profile", "p", nil, "SSO profile (not mandatory), can be repeated")
 	syncCmd.Flags().StringVarP(&podName, "pod", "n", "", "string that have to match with the pod name")
 	syncCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	addPodFilterFlags(syncCmd)
+	syncCmd.Flags().IntVarP(&workers, "workers", "w", 4, "Number of time shards fetched concurrently")
+	syncCmd.Flags().StringVar(&filterPattern, "filter-pattern", "", "CloudWatch filter pattern applied server side (default: derived from -n)")
+	syncCmd.Flags().StringVar(&parserName, "parser", "auto", "Format of the log events: auto, fluentd, fluentbit or raw")
+	syncCmd.Flags().BoolVar(&incremental, "incremental", false, "Resume from the last event synced for the profile/group/pod (-b only needed the first time, -e defaults to now)")
+	syncCmd.Flags().BoolVar(&withRetention, "retention", false, "Apply the retention of the config file after the sync (see the gc command)")
+	syncCmd.Flags().StringSliceVar(&regionNames, "region", nil, "AWS region (default: the region of the environment or of the profile), can be repeated")
+	syncCmd.Flags().StringSliceVar(&contextNames, "contexts", nil, "Contexts of the config file used together, instead of -p, --region and -g")
+	addAWSFlags(syncCmd)
 	rootCmd.AddCommand(syncCmd)
 
 	purgeCmd.Flags().StringVarP(&groupName, "group", "g", "", "Group name (not mandatory if there is only one log group : /aws/containerinsights/<Name of your cluster>/application)")
 	purgeCmd.Flags().StringVarP(&ssoProfile, "profile", "p", "", "SSO profile (not mandatory)")
 	purgeCmd.Flags().StringVarP(&podName, "pod", "n", "", "string that have to match with the pod name")
 	purgeCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	addPodFilterFlags(purgeCmd)
 	rootCmd.AddCommand(purgeCmd)
 
-	reqCmd.Flags().StringVarP(&beginDate, "begin", "b", "", "Begin date")
-	reqCmd.Flags().StringVarP(&endDate, "end", "e", "", "End date")
-	reqCmd.Flags().StringVarP(&groupName, "group", "g", "", "Group name (not mandatory if there is only one log group : /aws/containerinsights/<Name of your cluster>/application)")
-	reqCmd.Flags().StringVarP(&ssoProfile, "profile", "p", "", "SSO profile (not mandatory)")
+	addPeriodFlags(reqCmd)
+	reqCmd.Flags().StringSliceVarP(&groupNames, "group", "g", nil, "Group name (not mandatory if there is only one log group : /aws/containerinsights/<Name of your cluster>/application), can be repeated")
+	reqCmd.Flags().StringSliceVarP(&profileNames, "profile", "p", nil, "SSO profile (not mandatory), can be repeated")
 	reqCmd.Flags().StringVarP(&podName, "podname", "n", "", "string that have to match with the pod name")
 	reqCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	addPodFilterFlags(reqCmd)
 	reqCmd.Flags().BoolVarP(&containerName, "container-name", "c", false, "Show container name column")
 	reqCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colorized output")
+	reqCmd.Flags().StringSliceVar(&labelFilters, "label", nil, "Label of the pods as key=value, can be repeated")
+	reqCmd.Flags().StringVar(&imageFilter, "image", "", "string that have to match with the container image")
+	reqCmd.Flags().StringVar(&nodeFilter, "node", "", "Node of the pods")
+	reqCmd.Flags().BoolVar(&stderrOnly, "stderr-only", false, "Only print the logs written on stderr")
+	reqCmd.Flags().StringVar(&search, "search", "", "Full-text search on the logs (e.g. 'timeout AND payment'), the most relevant logs are printed first")
+	reqCmd.Flags().StringArrayVar(&grepPatterns, "grep", nil, "Only print the logs matching the regular expression, can be repeated to match any of them")
+	reqCmd.Flags().StringArrayVar(&grepVPatterns, "grep-v", nil, "Do not print the logs matching the regular expression, can be repeated")
+	reqCmd.Flags().StringVar(&minLevel, "level", "", "Only print the logs of this level or above: trace, debug, info, warn, error or fatal")
+	reqCmd.Flags().StringVarP(&outputFormat, "output", "o", outputText, "Output format: text, json, ndjson, csv, tsv, logfmt or raw")
+	reqCmd.Flags().StringVar(&templateText, "template", "", "Go template of the output lines (e.g. '{{.EventTime | time}} {{.Namespace}}/{{.Pod}} {{.Log}}') or name of a template of the config file")
+	reqCmd.Flags().IntVar(&limitLogs, "limit", 0, "Maximum number of logs to print (0: no limit)")
+	reqCmd.Flags().IntVar(&offsetLogs, "offset", 0, "Number of logs to skip")
+	reqCmd.Flags().IntVar(&headLogs, "head", 0, "Print the first N logs")
+	reqCmd.Flags().IntVar(&tailLogs, "tail", 0, "Print the last N logs")
+	reqCmd.Flags().BoolVar(&reverseOrder, "reverse", false, "Print the most recent logs first")
+	reqCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep fetching the new logs from CloudWatch and print them until interrupted")
+	reqCmd.Flags().DurationVar(&followInterval, "follow-interval", 5*time.Second, "Interval between two fetches of --follow")
+	reqCmd.Flags().StringVar(&parserName, "parser", "auto", "Format of the log events fetched by --follow: auto, fluentd, fluentbit or raw")
+	reqCmd.Flags().StringVar(&timeFormat, "time-format", "default", "Format of the event time: default, rfc3339, rfc3339nano, unixms, relative, delta or a Go time layout")
+	reqCmd.Flags().StringSliceVar(&regionNames, "region", nil, "AWS region (default: the region of the environment or of the profile), can be repeated")
+	reqCmd.Flags().StringSliceVar(&contextNames, "contexts", nil, "Contexts of the config file used together, instead of -p, --region and -g")
+	addAWSFlags(reqCmd)
 	rootCmd.AddCommand(reqCmd)
 
+	daemonCmd.Flags().StringVarP(&groupName, "group", "g", "", "Group name (not mandatory if there is only one log group : /aws/containerinsights/<Name of your cluster>/application)")
+	daemonCmd.Flags().StringVarP(&ssoProfile, "profile", "p", "", "SSO profile (not mandatory)")
+	daemonCmd.Flags().StringVarP(&podName, "pod", "n", "", "string that have to match with the pod name")
+	daemonCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	addPodFilterFlags(daemonCmd)
+	daemonCmd.Flags().StringVar(&daemonInterval, "interval", "1m", "Interval between two syncs of a target")
+	daemonCmd.Flags().StringVar(&daemonKeep, "keep", "6h", "Period before now synced the first time and after a long stop")
+	daemonCmd.Flags().StringVar(&pidFile, "pid-file", "", "PID file locked while the daemon runs (default: <database>.daemon.pid)")
+	daemonCmd.Flags().IntVarP(&workers, "workers", "w", 4, "Number of time shards fetched concurrently")
+	daemonCmd.Flags().StringVar(&parserName, "parser", "auto", "Format of the log events: auto, fluentd, fluentbit or raw")
+	daemonCmd.Flags().StringVar(&awsRegion, "region", "", "AWS region (default: the region of the environment or of the profile)")
+	addAWSFlags(daemonCmd)
+	rootCmd.AddCommand(daemonCmd)
+
+	gcCmd.Flags().StringVar(&maxAge, "max-age", "", "Remove the logs older than this duration (e.g. 30d), instead of the max_age of the config file")
+	gcCmd.Flags().Int64Var(&maxRows, "max-rows", 0, "Keep only this number of logs by loggroup, instead of the max_rows of the config file")
+	gcCmd.Flags().StringVar(&maxSize, "max-size", "", "Remove the oldest logs beyond this size of the database (e.g. 2GB), instead of the max_size of the config file")
+	rootCmd.AddCommand(gcCmd)
+
+	dbCmd.AddCommand(dbMigrateCmd, dbRollbackCmd, dbVersionCmd, dbWorkspacesCmd)
+	rootCmd.AddCommand(dbCmd)
+
+	contextCmd.AddCommand(contextUseCmd, contextListCmd, contextShowCmd)
+	rootCmd.AddCommand(contextCmd)
+
+	statusCmd.Flags().StringVarP(&groupName, "group", "g", "", "Only print the periods of this log group")
+	statusCmd.Flags().StringVarP(&ssoProfile, "profile", "p", "", "Only print the periods of this SSO profile")
+	statusCmd.Flags().StringVar(&timezone, "tz", "Local", "Time zone of the dates printed: Local, UTC or a name like Europe/Paris")
+	rootCmd.AddCommand(statusCmd)
+
 	listGroupsCmd.Flags().StringVarP(&ssoProfile, "profile", "p", "", "SSO profile (not mandatory)")
 	listGroupsCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	listGroupsCmd.Flags().StringVar(&awsRegion, "region", "", "AWS region (default: the region of the environment or of the profile)")
+	addAWSFlags(listGroupsCmd)
 	rootCmd.AddCommand(listGroupsCmd)
 
 	rootCmd.AddCommand(versionCmd)
 }
+
+// addAWSFlags adds the flags of the roles assumed to connect to the AWS API
+func addAWSFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&roleARNs, "role-arn", nil, "Role assumed with the credentials of the profile, can be repeated to chain the roles")
+	cmd.Flags().StringVar(&externalID, "external-id", "", "External ID of the last role of --role-arn")
+	cmd.Flags().StringVar(&roleSessionName, "role-session-name", "", "Name of the sessions of the roles of --role-arn (default: ekspodlogs)")
+	cmd.Flags().StringVar(&mfaSerial, "mfa-serial", "", "Serial number or ARN of the MFA device of the first role of --role-arn, the token code is asked on the terminal")
+}
+
+// addPeriodFlags adds the flags giving the period of the logs: -b, -e, --since, --until, --last, --around and --tz
+func addPeriodFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&beginDate, "begin", "b", "", "Begin date (e.g. \"2025-03-01 12:00\", yesterday, 2h)")
+	cmd.Flags().StringVarP(&endDate, "end", "e", "", "End date (default: now)")
+	cmd.Flags().StringVar(&sinceDate, "since", "", "Begin date or duration before now (e.g. 2h, yesterday)")
+	cmd.Flags().StringVar(&lastDuration, "last", "", "Duration before now (e.g. 30m)")
+	cmd.Flags().StringVar(&untilDate, "until", "", "End date (e.g. now, \"2025-03-01 13:00\")")
+	cmd.Flags().StringVar(&aroundDate, "around", "", "Date at the center of the period, see --window")
+	cmd.Flags().StringVar(&window, "window", "5m", "Duration before and after the date given by --around")
+	cmd.Flags().StringVar(&timezone, "tz", "Local", "Time zone of the dates given and printed: Local, UTC or a name like Europe/Paris")
+}
+
+// addPodFilterFlags adds the flags filtering the logs on the namespace and the containers
+func addPodFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&namespaceFilter, "namespace", "", "Namespace of the pods")
+	cmd.Flags().StringVar(&containerFilter, "container", "", "Name of the container")
+	cmd.Flags().StringSliceVar(&excludedContainers, "exclude-container", nil, "Name of the containers to ignore (e.g. istio-proxy), can be repeated")
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/coverage"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "print the periods synchronised in the local database",
+	Long: `print the periods synchronised in the local database.
+For each profile, log group and filter, it prints the merged periods covered by the syncs and the gaps between them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		InitDB() // Initialize the database and exit if an error occurs
+		defer func() {
+			if err := s.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error closing database: %v\n", err)
+			}
+		}()
+
+		loc, err := loadLocation(timezone)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		windows, err := s.GetSyncWindows(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		var selected []database.SyncWindow
+		for _, w := range windows {
+			if ssoProfile != "" && w.Profile != ssoProfile {
+				continue
+			}
+			if groupName != "" && w.Loggroup != groupName {
+				continue
+			}
+			selected = append(selected, w)
+		}
+		if len(selected) == 0 {
+			fmt.Println("No synchronised period found")
+			return
+		}
+
+		// Windows are ordered by profile, loggroup and filter
+		begin := 0
+		for i := range selected {
+			if i+1 < len(selected) && sameTarget(selected[i], selected[i+1]) {
+				continue
+			}
+			printStatus(os.Stdout, selected[begin:i+1], loc)
+			begin = i + 1
+		}
+	},
+}
+
+func sameTarget(a, b database.SyncWindow) bool {
+	return a.Profile == b.Profile && a.Region == b.Region && a.AccountID == b.AccountID &&
+		a.Loggroup == b.Loggroup && a.PodFilter == b.PodFilter &&
+		a.NamespaceFilter == b.NamespaceFilter && a.ContainerFilter == b.ContainerFilter &&
+		a.ExcludedContainers == b.ExcludedContainers
+}
+
+// printStatus prints the coverage of windows sharing the same source and filter, in the time zone loc
+func printStatus(w io.Writer, windows []database.SyncWindow, loc *time.Location) {
+	var intervals []coverage.Interval
+	var events int64
+	var lastSync time.Time
+	for _, win := range windows {
+		intervals = append(intervals, coverage.Interval{Begin: win.BeginTime, End: win.EndTime})
+		events += win.EventCount
+		if win.FinishedAt.After(lastSync) {
+			lastSync = win.FinishedAt
+		}
+	}
+	merged := coverage.Merge(intervals)
+
+	filter := sqlite.FilterOfSyncWindow(windows[0])
+	fmt.Fprintf(w, "Profile: %s\tLog group: %s\tFilter: %s\n", windows[0].Profile, windows[0].Loggroup, filter)
+	if windows[0].Region != "" || windows[0].AccountID != "" {
+		fmt.Fprintf(w, "  Account %s, region %s\n", windows[0].AccountID, windows[0].Region)
+	}
+	fmt.Fprintf(w, "  %d syncs, %d events, last sync at %s\n", len(windows), events, lastSync.In(loc).Format("2006-01-02 15:04:05"))
+	for i, in := range merged {
+		if i > 0 {
+			fmt.Fprintf(w, "  Gap\t\t%s\n", formatInterval(coverage.Interval{Begin: merged[i-1].End, End: in.Begin}, loc))
+		}
+		fmt.Fprintf(w, "  Covered\t%s\n", formatInterval(in, loc))
+	}
+}
+
+// printGaps prints the periods that have not been synchronised, in the time zone loc
+func printGaps(w io.Writer, gaps []coverage.Interval, loc *time.Location) {
+	fmt.Fprintln(w, "Warning: the requested period is not fully synchronised (see the status command), missing:")
+	for _, g := range gaps {
+		fmt.Fprintf(w, "  %s\n", formatInterval(g, loc))
+	}
+}
+
+func formatInterval(in coverage.Interval, loc *time.Location) string {
+	return fmt.Sprintf("%s -> %s", in.Begin.In(loc).Format("2006-01-02 15:04:05"), in.End.In(loc).Format("2006-01-02 15:04:05"))
+}
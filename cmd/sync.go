@@ -6,9 +6,12 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/dromara/carbon/v2"
 	"github.com/sgaunet/ekspodlogs/internal/app"
+	"github.com/sgaunet/ekspodlogs/pkg/parser"
 	"github.com/sgaunet/ekspodlogs/pkg/views"
 	"github.com/spf13/cobra"
 )
@@ -23,6 +26,19 @@ var syncCmd = &cobra.Command{
 		var err error
 		ctx := context.Background()
 
+		// The retention is applied after the sync with --retention, or after_sync in the config file
+		conf, err := loadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		applyAfterSync := withRetention || conf.Retention.AfterSync
+		retention, err := currentRetention(conf)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
 		InitDB() // Initialize the database and exit if an error occurs
 
 		// Set up signal handling for graceful shutdown
@@ -51,7 +67,63 @@ var syncCmd = &cobra.Command{
 			}
 		}()
 
-		cfg, err = InitAWSConfig(ctx, ssoProfile)
+		logParser, err := parser.ByName(parserName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		// Filter the events in CloudWatch, derive the pattern from the filter by default
+		// The derived pattern applies to JSON events only, raw events would all be discarded
+		filter := currentLogFilter()
+		pattern := filterPattern
+		if pattern == "" && logParser.Name() != parser.RawName {
+			pattern = app.FilterPattern(filter)
+		}
+
+		if incremental && filterPattern != "" {
+			// A custom pattern selects only a part of the events of the period
+			fmt.Fprintln(os.Stderr, "--incremental and --filter-pattern cannot be combined")
+			os.Exit(1)
+		}
+
+		begin, end, err := resolvePeriod(time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if !incremental {
+			if begin.IsZero() {
+				fmt.Fprintln(os.Stderr, "begin date must be specified (-b, --since, --last or --around)")
+				os.Exit(1)
+			}
+			if end.IsZero() {
+				end = time.Now()
+			}
+		}
+
+		targets, err := currentTargets()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if len(targets) > 1 {
+			// Several profiles, regions or log groups are synchronised concurrently
+			if err := syncTargets(ctx, targets, filter, pattern, logParser, begin, end); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			if applyAfterSync {
+				if err := applyRetention(ctx, retention); err != nil {
+					fmt.Fprintln(os.Stderr, err.Error())
+					os.Exit(1)
+				}
+			}
+			return
+		}
+		ssoProfile, groupName = targets[0].aws.Profile, targets[0].group
+
+		cfg, err = InitAWSConfig(ctx, targets[0].aws)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "unable to load SDK config: %s", err.Error())
 			os.Exit(1)
@@ -63,8 +135,11 @@ var syncCmd = &cobra.Command{
 		// Configure logger based on debug flag
 		logger := NewLoggerWithDebug(debug)
 		app.SetLogger(logger)
+		app.SetWorkers(workers)
+		app.SetFilterPattern(pattern)
+		app.SetParser(logParser)
 		
-		if err = app.PrintID(); err != nil {
+		if err = app.PrintID(os.Stdout); err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
@@ -82,28 +157,50 @@ var syncCmd = &cobra.Command{
 			}
 		}
 
-		if beginDate == "" || endDate == "" {
-			fmt.Fprintln(os.Stderr, "begin and end dates must be specified")
-			os.Exit(1)
+		var b, e *carbon.Carbon
+		if incremental {
+			b, e, err = IncrementalPeriod(ctx, s, app.Source(groupName), filter, begin, end)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		} else {
+			b, e, err = ConvertTimeToCarbon(begin, end)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
 		}
 
-		b, e, err := ConvertTimeToCarbon(beginDate, endDate)
+		res, err := app.PrintEvents(ctx, groupName, filter, b.StdTime(), e.StdTime())
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			os.Exit(1)
 		}
 
-		// Purge DB
-		err = s.PurgeSpecificPeriod(ctx, ssoProfile, groupName, podName, b, e)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
+		// With a custom pattern, the period is not fully synchronised for the filter
+		if filterPattern == "" {
+			err = s.AddSyncWindow(ctx, app.Source(groupName), filter, b.StdTime(), e.StdTime(), res.EventCount)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
 		}
 
-		err = app.PrintEvents(ctx, groupName, podName, b.StdTime(), e.StdTime())
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
+		if incremental {
+			watermark := syncWatermark(res.LastEventTime, e.StdTime(), time.Now())
+			err = s.SetSyncWatermark(ctx, app.Source(groupName), filter, watermark)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		}
+
+		if applyAfterSync {
+			if err := applyRetention(ctx, retention); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
 		}
 	},
 }
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/sgaunet/ekspodlogs/internal/app"
+	"github.com/sgaunet/ekspodlogs/pkg/parser"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+	"github.com/sgaunet/ekspodlogs/pkg/views"
+)
+
+// target is a log group of a profile in a region, synced or requested
+type target struct {
+	aws   AWSOptions
+	group string // Found automatically if empty
+}
+
+func (t target) String() string {
+	name := t.aws.Profile
+	if name == "" {
+		name = "default"
+	}
+	if t.aws.Region != "" {
+		name += "/" + t.aws.Region
+	}
+	if t.group != "" {
+		name += " " + t.group
+	}
+	return name
+}
+
+// currentTargets returns the targets of the contexts given by --contexts,
+// or all the combinations of the profiles, regions and log groups given by -p, --region and -g
+func currentTargets() ([]target, error) {
+	if len(contextNames) > 0 {
+		cfg, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		targets := make([]target, 0, len(contextNames))
+		for _, name := range contextNames {
+			c, err := cfg.GetContext(name)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, target{
+				aws: AWSOptions{
+					Profile:         c.Profile,
+					Region:          c.Region,
+					RoleARNs:        c.RoleARNs,
+					ExternalID:      c.ExternalID,
+					RoleSessionName: c.RoleSessionName,
+					MFASerial:       c.MFASerial,
+				},
+				group: c.Group,
+			})
+		}
+		return targets, nil
+	}
+
+	profiles, regions, groups := profileNames, regionNames, groupNames
+	if len(profiles) == 0 {
+		profiles = []string{""}
+	}
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	var targets []target
+	for _, p := range profiles {
+		for _, r := range regions {
+			for _, g := range groups {
+				opts := currentAWSOptions(p)
+				opts.Region = r
+				targets = append(targets, target{aws: opts, group: g})
+			}
+		}
+	}
+	return targets, nil
+}
+
+// storageTargets returns the selection of the logs of the targets in the database
+// The logs synced by the previous versions, without region, are selected in all the regions.
+func storageTargets(targets []target) sqlite.Targets {
+	var st sqlite.Targets
+	allRegions, allGroups := false, false
+	for _, t := range targets {
+		if !slices.Contains(st.Profiles, t.aws.Profile) {
+			st.Profiles = append(st.Profiles, t.aws.Profile)
+		}
+		if t.aws.Region == "" {
+			allRegions = true
+		} else if !slices.Contains(st.Regions, t.aws.Region) {
+			st.Regions = append(st.Regions, t.aws.Region)
+		}
+		if t.group == "" {
+			allGroups = true
+		} else if !slices.Contains(st.Loggroups, t.group) {
+			st.Loggroups = append(st.Loggroups, t.group)
+		}
+	}
+	if allRegions {
+		st.Regions = nil
+	} else {
+		st.Regions = append(st.Regions, "")
+	}
+	if allGroups {
+		st.Loggroups = nil
+	}
+	return st
+}
+
+// syncTargets synchronises the targets concurrently, the errors of the targets are returned joined
+// The credentials are resolved and the log groups found one target at a time, MFA codes may be asked.
+func syncTargets(ctx context.Context, targets []target, filter sqlite.LogFilter, pattern string, logParser parser.Parser, begin, end time.Time) error {
+	apps := make([]*app.App, len(targets))
+	for i, t := range targets {
+		cfg, err := InitAWSConfig(ctx, t.aws)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t, err)
+		}
+		a := app.New(cfg, t.aws.Profile, s, views.NewTerminalView())
+		a.SetLogger(NewLoggerWithDebug(debug))
+		a.SetWorkers(workers)
+		a.SetFilterPattern(pattern)
+		a.SetParser(logParser)
+		identity, err := a.GetIdentity(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t, err)
+		}
+		if t.group == "" {
+			group, err := a.FindLogGroupAuto(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", t, err)
+			}
+			if group == "" {
+				return fmt.Errorf("%s: log group not found automatically (add option -g)", t)
+			}
+			targets[i].group = group
+		}
+		fmt.Printf("%s: AWS identity: %s\n", targets[i], identity)
+		apps[i] = a
+	}
+
+	var mu sync.Mutex // serialises the output of the targets
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := syncTarget(ctx, apps[i], t, filter, begin, end)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", t, err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Printf("%s: %d events synchronised\n", t, res.EventCount)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// syncTarget fetches the events of the period, or of the incremental period, and records the sync
+func syncTarget(ctx context.Context, a *app.App, t target, filter sqlite.LogFilter, begin, end time.Time) (app.SyncResult, error) {
+	var b, e *carbon.Carbon
+	var err error
+	if incremental {
+		b, e, err = IncrementalPeriod(ctx, s, a.Source(t.group), filter, begin, end)
+	} else {
+		b, e, err = ConvertTimeToCarbon(begin, end)
+	}
+	if err != nil {
+		return app.SyncResult{}, err
+	}
+	res, err := a.FetchEvents(ctx, t.group, filter, b.StdTime(), e.StdTime())
+	if err != nil {
+		return res, err
+	}
+	// With a custom pattern, the period is not fully synchronised for the filter
+	if filterPattern == "" {
+		if err := s.AddSyncWindow(ctx, a.Source(t.group), filter, b.StdTime(), e.StdTime(), res.EventCount); err != nil {
+			return res, err
+		}
+	}
+	if incremental {
+		watermark := syncWatermark(res.LastEventTime, e.StdTime(), time.Now())
+		if err := s.SetSyncWatermark(ctx, a.Source(t.group), filter, watermark); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sgaunet/ekspodlogs/internal/config"
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/level"
+)
+
+// resolveTemplate returns the text of the --template option, which is either
+// a Go template or the name of a template of the configuration file
+func resolveTemplate(value string, cfg *config.Config) (string, error) {
+	if strings.Contains(value, "{{") {
+		return value, nil
+	}
+	if text, ok := cfg.Templates[value]; ok {
+		return text, nil
+	}
+	return "", fmt.Errorf("unknown template %q, add it to the templates of the config file or give a Go template", value)
+}
+
+// templateFuncs returns the helper functions available in the templates:
+//   - time: formats a time with the --time-format option ({{.EventTime | time}})
+//   - date: formats a time with a Go layout in the --tz time zone ({{.EventTime | date "15:04:05"}})
+//   - trunc: truncates a string to n characters ({{.Log | trunc 80}})
+//   - pad: pads a string with spaces to n characters ({{.Pod | pad 30}})
+//   - shortpod: removes the hash of the ReplicaSet from a pod name ({{.Pod | shortpod}})
+//   - upper, lower
+//   - color: colors a string according to a level ({{.Log | color .Level}})
+//   - json: returns a field of a JSON log, nested fields are separated by dots ({{.Log | json "user.id"}})
+func templateFuncs(formatTime func(time.Time) string, loc *time.Location, noColor bool) template.FuncMap {
+	return template.FuncMap{
+		"time": formatTime,
+		"date": func(layout string, t time.Time) string {
+			return t.In(loc).Format(layout)
+		},
+		"trunc": func(n int, s string) string {
+			if utf8.RuneCountInString(s) <= n {
+				return s
+			}
+			return string([]rune(s)[:n])
+		},
+		"pad": func(n int, s string) string {
+			if c := utf8.RuneCountInString(s); c < n {
+				return s + strings.Repeat(" ", n-c)
+			}
+			return s
+		},
+		"shortpod": shortPodName,
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"color": func(l string, s string) string {
+			return colorizeLog(s, level.Level(l), nil, noColor)
+		},
+		"json": jsonField,
+	}
+}
+
+// podHashAlphabet is the alphabet of the hashes generated by Kubernetes in the pod names
+const podHashAlphabet = "bcdfghjklmnpqrstvwxz2456789"
+
+// shortPodName removes the hash of the ReplicaSet from the name of a pod of a Deployment:
+// api-7d9f8b6c5-x2k4q becomes api-x2k4q
+func shortPodName(pod string) string {
+	parts := strings.Split(pod, "-")
+	n := len(parts)
+	if n < 3 || len(parts[n-1]) != 5 || len(parts[n-2]) < 6 || len(parts[n-2]) > 10 {
+		return pod
+	}
+	for _, r := range parts[n-2] + parts[n-1] {
+		if !strings.ContainsRune(podHashAlphabet, r) {
+			return pod
+		}
+	}
+	return strings.Join(append(parts[:n-2], parts[n-1]), "-")
+}
+
+// jsonField returns the field of a JSON log, nested fields are separated by dots
+// An empty string is returned if the log is not JSON or has no such field.
+func jsonField(path string, log string) string {
+	var v any
+	if err := json.Unmarshal([]byte(log), &v); err != nil {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		if v, ok = m[key]; !ok {
+			return ""
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// templateWriter writes each log with a Go template
+type templateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+	buf  bytes.Buffer
+}
+
+// newTemplateWriter returns a writer executing the template text for each log
+// The template is given an outputRecord: .EventTime, .Namespace, .Pod, .Container, .Level, .Labels, .Log, ...
+func newTemplateWriter(w io.Writer, text string, formatTime func(time.Time) string, loc *time.Location, noColor bool) (*templateWriter, error) {
+	tmpl, err := template.New("req").Funcs(templateFuncs(formatTime, loc, noColor)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return &templateWriter{w: w, tmpl: tmpl}, nil
+}
+
+func (t *templateWriter) Write(r database.Log, _ [][]int) error {
+	t.buf.Reset()
+	if err := t.tmpl.Execute(&t.buf, newOutputRecord(r)); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	if !bytes.HasSuffix(t.buf.Bytes(), []byte("\n")) {
+		t.buf.WriteByte('\n')
+	}
+	_, err := t.w.Write(t.buf.Bytes())
+	return err
+}
+
+func (t *templateWriter) Flush() error {
+	return nil
+}
+
+func (t *templateWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import "testing"
+
+func TestShortPodName(t *testing.T) {
+	tests := []struct {
+		pod  string
+		want string
+	}{
+		{"api-7d9f8b6c5-x2k4q", "api-x2k4q"},
+		{"payment-api-7d9f8b6c5-x2k4q", "payment-api-x2k4q"},
+		{"worker-1", "worker-1"},                           // StatefulSet
+		{"node-exporter-x2k4q", "node-exporter-x2k4q"},     // DaemonSet, no hash of ReplicaSet
+		{"api-7d9f8b6c5-x2k4a", "api-7d9f8b6c5-x2k4a"},     // a is not in the alphabet of the hashes
+		{"api-7d9f8b6c5bc-x2k4q", "api-7d9f8b6c5bc-x2k4q"}, // hash too long
+		{"7d9f8b6c5-x2k4q", "7d9f8b6c5-x2k4q"},             // no name
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := shortPodName(tt.pod); got != tt.want {
+			t.Errorf("shortPodName(%q) = %q, want %q", tt.pod, got, tt.want)
+		}
+	}
+}
+
+func TestJSONField(t *testing.T) {
+	log := `{"msg":"done","request":{"id":"42","status":200,"tags":["a","b"],"user":null},"ok":true}`
+	tests := []struct {
+		path string
+		log  string
+		want string
+	}{
+		{"msg", log, "done"},
+		{"request.id", log, "42"},
+		{"request.status", log, "200"},
+		{"request.tags", log, `["a","b"]`},
+		{"ok", log, "true"},
+		{"request.user", log, ""},
+		{"request", `{"request":{"id":"42"}}`, `{"id":"42"}`},
+		{"missing", log, ""},
+		{"request.missing", log, ""},
+		{"msg.id", log, ""}, // msg is not an object
+		{"msg", "plain text", ""},
+		{"msg", `{"msg":`, ""},
+		{"msg", "", ""},
+	}
+	for _, tt := range tests {
+		if got := jsonField(tt.path, tt.log); got != tt.want {
+			t.Errorf("jsonField(%q, %q) = %q, want %q", tt.path, tt.log, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeLayout is the layout used to print the event times, with millisecond precision
+const defaultTimeLayout = "2006-01-02 15:04:05.000"
+
+// newTimeFormatter returns a function that formats the event times according to the --time-format option
+// format can be one of:
+//   - default: 2006-01-02 15:04:05.000
+//   - rfc3339, rfc3339nano
+//   - unixms: milliseconds since epoch
+//   - relative: duration elapsed since now (e.g. 2m3.5s ago)
+//   - delta: duration elapsed since the previous event (e.g. +120ms)
+//   - any other value is used as a Go time layout
+//
+// The times are printed in the time zone loc.
+func newTimeFormatter(format string, now time.Time, loc *time.Location) func(time.Time) string {
+	formatTime := timeFormatter(format, now)
+	return func(t time.Time) string {
+		return formatTime(t.In(loc))
+	}
+}
+
+// timeFormatter returns the function formatting the times according to format, see newTimeFormatter
+func timeFormatter(format string, now time.Time) func(time.Time) string {
+	switch strings.ToLower(format) {
+	case "", "default":
+		return func(t time.Time) string { return t.Format(defaultTimeLayout) }
+	case "rfc3339":
+		return func(t time.Time) string { return t.Format(time.RFC3339) }
+	case "rfc3339nano":
+		return func(t time.Time) string { return t.Format(time.RFC3339Nano) }
+	case "unixms":
+		return func(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) }
+	case "relative":
+		return func(t time.Time) string {
+			return fmt.Sprintf("%s ago", now.Sub(t).Round(time.Millisecond))
+		}
+	case "delta":
+		var previous time.Time
+		return func(t time.Time) string {
+			if previous.IsZero() {
+				previous = t
+			}
+			d := t.Sub(previous)
+			previous = t
+			return fmt.Sprintf("+%s", d.Round(time.Millisecond))
+		}
+	default:
+		return func(t time.Time) string { return t.Format(format) }
+	}
+}
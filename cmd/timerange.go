@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+)
+
+// durationPattern matches the durations accepted by the period options: 90s, 30m, 2h, 1d, 1w, 1h30m
+var durationPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?(?:ms|s|m|h|d|w))+$`)
+
+var durationPart = regexp.MustCompile(`(\d+(?:\.\d+)?)(ms|s|m|h|d|w)`)
+
+// parseDuration parses a duration, with the d (day) and w (week) units in addition to the units of Go
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if !durationPattern.MatchString(s) {
+		return 0, fmt.Errorf("invalid duration %q (e.g. 30m, 2h, 1d)", s)
+	}
+	units := map[string]time.Duration{
+		"ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour,
+		"d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+	}
+	var d time.Duration
+	for _, m := range durationPart.FindAllStringSubmatch(s, -1) {
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		d += time.Duration(v * float64(units[m[2]]))
+	}
+	return d, nil
+}
+
+// loadLocation returns the time zone given by --tz: UTC by default, Local or an IANA name (Europe/Paris)
+func loadLocation(tz string) (*time.Location, error) {
+	switch strings.ToLower(tz) {
+	case "", "local":
+		return time.Local, nil
+	case "utc":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", tz, err)
+	}
+	return loc, nil
+}
+
+// parseTime parses a date given to a period option, in the time zone loc:
+//   - now, today, yesterday, tomorrow
+//   - a duration before now: 2h, 2h ago, -2h
+//   - an absolute date: 2025-03-01, 2025-03-01 12:00, 2025-03-01T12:00:00Z, ...
+func parseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	now = now.In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	switch strings.ToLower(s) {
+	case "now":
+		return now, nil
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return midnight.AddDate(0, 0, 1), nil
+	}
+	ago := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(s, "-"), "ago"))
+	if durationPattern.MatchString(ago) {
+		d, err := parseDuration(ago)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(-d), nil
+	}
+	c := carbon.Parse(s, loc.String())
+	if c == nil || c.Error != nil || c.IsZero() {
+		return time.Time{}, fmt.Errorf("unable to parse %q (e.g. 2025-03-01 12:00, yesterday, 2h)", s)
+	}
+	return c.StdTime(), nil
+}
+
+// resolvePeriod returns the period given by -b, -e, --since, --last, --until, --around and --window
+// The begin or the end is zero when it is not given.
+func resolvePeriod(now time.Time) (begin time.Time, end time.Time, err error) {
+	loc, err := loadLocation(timezone)
+	if err != nil {
+		return begin, end, err
+	}
+	nbBegin, nbEnd := 0, 0
+	for _, v := range []string{beginDate, sinceDate, lastDuration, aroundDate} {
+		if v != "" {
+			nbBegin++
+		}
+	}
+	for _, v := range []string{endDate, untilDate, aroundDate} {
+		if v != "" {
+			nbEnd++
+		}
+	}
+	if nbBegin > 1 {
+		return begin, end, errors.New("only one of -b, --since, --last and --around can be given")
+	}
+	if nbEnd > 1 {
+		return begin, end, errors.New("only one of -e, --until and --around can be given")
+	}
+
+	switch {
+	case aroundDate != "":
+		t, err := parseTime(aroundDate, now, loc)
+		if err != nil {
+			return begin, end, err
+		}
+		w, err := parseDuration(window)
+		if err != nil {
+			return begin, end, err
+		}
+		return t.Add(-w), t.Add(w), nil
+	case lastDuration != "":
+		if nbEnd > 0 {
+			return begin, end, errors.New("--last can not be combined with -e or --until, it ends now")
+		}
+		d, err := parseDuration(lastDuration)
+		if err != nil {
+			return begin, end, err
+		}
+		begin = now.Add(-d)
+	case sinceDate != "":
+		if begin, err = parseTime(sinceDate, now, loc); err != nil {
+			return begin, end, err
+		}
+	case beginDate != "":
+		if begin, err = parseTime(beginDate, now, loc); err != nil {
+			return begin, end, fmt.Errorf("invalid begin date: %w", err)
+		}
+	}
+
+	switch {
+	case untilDate != "":
+		end, err = parseTime(untilDate, now, loc)
+	case endDate != "":
+		end, err = parseTime(endDate, now, loc)
+	}
+	if err != nil {
+		return begin, end, fmt.Errorf("invalid end date: %w", err)
+	}
+	return begin, end, nil
+}
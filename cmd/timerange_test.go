@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90s", 90 * time.Second, false},
+		{"30m", 30 * time.Minute, false},
+		{"2h", 2 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"1w", 7 * 24 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"1.5h", 90 * time.Minute, false},
+		{"500ms", 500 * time.Millisecond, false},
+		{" 2h ", 2 * time.Hour, false},
+		{"", 0, true},
+		{"2", 0, true},
+		{"2x", 0, true},
+		{"h", 0, true},
+		{"-2h", 0, true},
+		{"2h 30m", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDuration(%q) returned the error %v, want error %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		tz      string
+		want    string
+		wantErr bool
+	}{
+		{"", "Local", false},
+		{"utc", "UTC", false},
+		{"Local", "Local", false},
+		{"Europe/Paris", "Europe/Paris", false},
+		{"Mars/Olympus_Mons", "", true},
+	}
+	for _, tt := range tests {
+		loc, err := loadLocation(tt.tz)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("loadLocation(%q) returned the error %v, want error %v", tt.tz, err, tt.wantErr)
+			continue
+		}
+		if err == nil && loc.String() != tt.want {
+			t.Errorf("loadLocation(%q) = %v, want %v", tt.tz, loc, tt.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	now := time.Date(2025, 3, 10, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		s       string
+		loc     *time.Location
+		want    time.Time
+		wantErr bool
+	}{
+		{"now", time.UTC, now, false},
+		{"today", time.UTC, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), false},
+		{"Yesterday", time.UTC, time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC), false},
+		{"tomorrow", time.UTC, time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), false},
+		{"today", paris, time.Date(2025, 3, 9, 23, 0, 0, 0, time.UTC), false},
+		{"2h", time.UTC, now.Add(-2 * time.Hour), false},
+		{"2h ago", time.UTC, now.Add(-2 * time.Hour), false},
+		{"-2h", time.UTC, now.Add(-2 * time.Hour), false},
+		{"1d", time.UTC, now.AddDate(0, 0, -1), false},
+		{"2025-03-01", time.UTC, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2025-03-01 12:00", time.UTC, time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"2025-03-01 12:00:05", time.UTC, time.Date(2025, 3, 1, 12, 0, 5, 0, time.UTC), false},
+		{"2025-03-01T12:00:00Z", paris, time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"2025-03-01 12:00", paris, time.Date(2025, 3, 1, 11, 0, 0, 0, time.UTC), false},
+		{"", time.UTC, time.Time{}, true},
+		{"garbage", time.UTC, time.Time{}, true},
+		{"2025-13-45", time.UTC, time.Time{}, true},
+		{"2x ago", time.UTC, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.s, now, tt.loc)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTime(%q, %v) returned the error %v, want error %v", tt.s, tt.loc, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTime(%q, %v) = %v, want %v", tt.s, tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePeriod(t *testing.T) {
+	defer setPeriodFlags(periodFlags{window: "5m", tz: "Local"})
+	now := time.Date(2025, 3, 10, 15, 30, 0, 0, time.UTC)
+	at := func(hour, min int) time.Time { return time.Date(2025, 3, 1, hour, min, 0, 0, time.UTC) }
+	tests := []struct {
+		name      string
+		flags     periodFlags
+		wantBegin time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{"none", periodFlags{}, time.Time{}, time.Time{}, false},
+		{"begin and end", periodFlags{begin: "2025-03-01 10:00", end: "2025-03-01 11:00"}, at(10, 0), at(11, 0), false},
+		{"last", periodFlags{last: "30m"}, now.Add(-30 * time.Minute), time.Time{}, false},
+		{"since and until", periodFlags{since: "2h", until: "1h"}, now.Add(-2 * time.Hour), now.Add(-time.Hour), false},
+		{"around", periodFlags{around: "2025-03-01 12:00", window: "5m"}, at(11, 55), at(12, 5), false},
+		{"time zone", periodFlags{begin: "2025-03-01 12:00", tz: "Europe/Paris"}, at(11, 0), time.Time{}, false},
+		{"begin and since", periodFlags{begin: "yesterday", since: "2h"}, time.Time{}, time.Time{}, true},
+		{"end and until", periodFlags{end: "now", until: "now"}, time.Time{}, time.Time{}, true},
+		{"last and end", periodFlags{last: "30m", end: "now"}, time.Time{}, time.Time{}, true},
+		{"around and end", periodFlags{around: "2025-03-01 12:00", end: "now"}, time.Time{}, time.Time{}, true},
+		{"invalid window", periodFlags{around: "2025-03-01 12:00", window: "5"}, time.Time{}, time.Time{}, true},
+		{"invalid last", periodFlags{last: "yesterday"}, time.Time{}, time.Time{}, true},
+		{"invalid begin", periodFlags{begin: "garbage"}, time.Time{}, time.Time{}, true},
+		{"invalid end", periodFlags{begin: "2h", end: "garbage"}, time.Time{}, time.Time{}, true},
+		{"invalid time zone", periodFlags{last: "30m", tz: "Mars/Olympus_Mons"}, time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The dates of the tests are in UTC, unless another time zone is given
+			if tt.flags.tz == "" {
+				tt.flags.tz = "UTC"
+			}
+			setPeriodFlags(tt.flags)
+			begin, end, err := resolvePeriod(now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolvePeriod() returned the error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (!begin.Equal(tt.wantBegin) || !end.Equal(tt.wantEnd)) {
+				t.Errorf("resolvePeriod() = %v, %v, want %v, %v", begin, end, tt.wantBegin, tt.wantEnd)
+			}
+		})
+	}
+}
+
+// periodFlags are the values of the flags of the period
+type periodFlags struct {
+	begin, end, since, until, last, around, window, tz string
+}
+
+func setPeriodFlags(f periodFlags) {
+	beginDate, endDate, sinceDate, untilDate = f.begin, f.end, f.since, f.until
+	lastDuration, aroundDate, window, timezone = f.last, f.around, f.window, f.tz
+}
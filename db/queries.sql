@@ -5,6 +5,9 @@ DELETE FROM logs;
 -- name: PurgeSpecificPeriod :exec
 DELETE FROM logs WHERE profile = sqlc.arg(profile) AND loggroup = sqlc.arg(loggroup)
     AND pod_name like sqlc.arg(pod_name)
+    AND (CAST(sqlc.arg(namespace_name) AS TEXT) = '' OR namespace_name = sqlc.arg(namespace_name))
+    AND (CAST(sqlc.arg(container_name) AS TEXT) = '' OR container_name = sqlc.arg(container_name))
+    AND instr(CAST(sqlc.arg(excluded_containers) AS TEXT), ',' || container_name || ',') = 0
     AND event_time >= sqlc.arg(begindate) 
     AND event_time <= sqlc.arg(enddate);
 
@@ -12,18 +15,98 @@ DELETE FROM logs WHERE profile = sqlc.arg(profile) AND loggroup = sqlc.arg(loggr
 DELETE FROM logs
 WHERE profile = sqlc.arg(profile) 
   AND loggroup = sqlc.arg(loggroup)
-  AND pod_name LIKE sqlc.arg(pod_name);
+  AND pod_name LIKE sqlc.arg(pod_name)
+    AND (CAST(sqlc.arg(namespace_name) AS TEXT) = '' OR namespace_name = sqlc.arg(namespace_name))
+    AND (CAST(sqlc.arg(container_name) AS TEXT) = '' OR container_name = sqlc.arg(container_name))
+    AND instr(CAST(sqlc.arg(excluded_containers) AS TEXT), ',' || container_name || ',') = 0;
 
 -- name: InsertLog :exec
-INSERT INTO logs (event_time, profile, loggroup, namespace_name, pod_name, container_name, log) VALUES (? , ?, ?, ?, ?, ?, ?);
+-- Events already saved by a previous sync are ignored
+INSERT OR IGNORE INTO logs (event_time, profile, loggroup, namespace_name, pod_name, container_name, log, event_id, log_stream_name, ingestion_time, container_image, pod_id, docker_id, host, stream, labels, level, region, account_id) VALUES (? , ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
--- name: GetLogs :many
-SELECT * FROM logs 
+-- name: GetLastLogID :one
+-- Identifier of the last log saved, the identifiers are increasing
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) AS id FROM logs;
+
+-- name: GetLogsPage :many
+-- Page of the logs following the log (cursor_time, cursor_id), in chronological order
+-- The lists of loggroups, profiles and regions are given as ",a,b,", empty to select all
+SELECT * FROM logs
 WHERE event_time >= sqlc.arg(begindate) and event_time <= sqlc.arg(enddate)
-    AND loggroup = sqlc.arg(loggroup)
-    AND profile = sqlc.arg(profile)
+    AND (event_time > sqlc.arg(cursor_time) OR (event_time = sqlc.arg(cursor_time) AND id > sqlc.arg(cursor_id)))
+    AND (CAST(sqlc.arg(loggroups) AS TEXT) = '' OR instr(sqlc.arg(loggroups), ',' || loggroup || ',') > 0)
+    AND (CAST(sqlc.arg(profiles) AS TEXT) = '' OR instr(sqlc.arg(profiles), ',' || profile || ',') > 0)
+    AND (CAST(sqlc.arg(regions) AS TEXT) = '' OR instr(sqlc.arg(regions), ',' || region || ',') > 0)
+    AND pod_name like sqlc.arg(pod_name)
+    AND (CAST(sqlc.arg(namespace_name) AS TEXT) = '' OR namespace_name = sqlc.arg(namespace_name))
+    AND (CAST(sqlc.arg(container_name) AS TEXT) = '' OR container_name = sqlc.arg(container_name))
+    AND instr(CAST(sqlc.arg(excluded_containers) AS TEXT), ',' || container_name || ',') = 0
+    AND container_image LIKE sqlc.arg(container_image)
+    AND (CAST(sqlc.arg(host) AS TEXT) = '' OR host = sqlc.arg(host))
+    AND (CAST(sqlc.arg(stream) AS TEXT) = '' OR stream = sqlc.arg(stream))
+    AND (
+        SELECT COUNT(*) FROM json_each(logs.labels)
+        WHERE instr(CAST(sqlc.arg(labels) AS TEXT), ',' || json_each.key || '=' || json_each.value || ',') > 0
+    ) = CAST(sqlc.arg(nb_labels) AS INTEGER)
+    AND (CAST(sqlc.arg(grep) AS TEXT) = '' OR logs.log REGEXP sqlc.arg(grep))
+    AND (CAST(sqlc.arg(grep_v) AS TEXT) = '' OR logs.log NOT REGEXP sqlc.arg(grep_v))
+    AND (CAST(sqlc.arg(levels) AS TEXT) = '' OR instr(sqlc.arg(levels), ',' || level || ',') > 0)
+    AND id > sqlc.arg(after_id)
+ORDER BY event_time, id
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: GetLogsPageReverse :many
+-- Page of the logs preceding the log (cursor_time, cursor_id), the most recent first
+SELECT * FROM logs
+WHERE event_time >= sqlc.arg(begindate) and event_time <= sqlc.arg(enddate)
+    AND (event_time < sqlc.arg(cursor_time) OR (event_time = sqlc.arg(cursor_time) AND id < sqlc.arg(cursor_id)))
+    AND (CAST(sqlc.arg(loggroups) AS TEXT) = '' OR instr(sqlc.arg(loggroups), ',' || loggroup || ',') > 0)
+    AND (CAST(sqlc.arg(profiles) AS TEXT) = '' OR instr(sqlc.arg(profiles), ',' || profile || ',') > 0)
+    AND (CAST(sqlc.arg(regions) AS TEXT) = '' OR instr(sqlc.arg(regions), ',' || region || ',') > 0)
+    AND pod_name like sqlc.arg(pod_name)
+    AND (CAST(sqlc.arg(namespace_name) AS TEXT) = '' OR namespace_name = sqlc.arg(namespace_name))
+    AND (CAST(sqlc.arg(container_name) AS TEXT) = '' OR container_name = sqlc.arg(container_name))
+    AND instr(CAST(sqlc.arg(excluded_containers) AS TEXT), ',' || container_name || ',') = 0
+    AND container_image LIKE sqlc.arg(container_image)
+    AND (CAST(sqlc.arg(host) AS TEXT) = '' OR host = sqlc.arg(host))
+    AND (CAST(sqlc.arg(stream) AS TEXT) = '' OR stream = sqlc.arg(stream))
+    AND (
+        SELECT COUNT(*) FROM json_each(logs.labels)
+        WHERE instr(CAST(sqlc.arg(labels) AS TEXT), ',' || json_each.key || '=' || json_each.value || ',') > 0
+    ) = CAST(sqlc.arg(nb_labels) AS INTEGER)
+    AND (CAST(sqlc.arg(grep) AS TEXT) = '' OR logs.log REGEXP sqlc.arg(grep))
+    AND (CAST(sqlc.arg(grep_v) AS TEXT) = '' OR logs.log NOT REGEXP sqlc.arg(grep_v))
+    AND (CAST(sqlc.arg(levels) AS TEXT) = '' OR instr(sqlc.arg(levels), ',' || level || ',') > 0)
+    AND id > sqlc.arg(after_id)
+ORDER BY event_time DESC, id DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: SearchLogs :many
+-- highlight() surrounds the matching terms with \x01 and \x02, the most relevant logs (lowest bm25) come first
+SELECT sqlc.embed(logs), CAST(highlight(logs_fts, 0, char(1), char(2)) AS TEXT) AS highlighted
+FROM logs_fts
+JOIN logs ON logs.id = logs_fts.rowid
+WHERE logs_fts.log MATCH sqlc.arg(search)
+    AND event_time >= sqlc.arg(begindate) and event_time <= sqlc.arg(enddate)
+    AND (CAST(sqlc.arg(loggroups) AS TEXT) = '' OR instr(sqlc.arg(loggroups), ',' || loggroup || ',') > 0)
+    AND (CAST(sqlc.arg(profiles) AS TEXT) = '' OR instr(sqlc.arg(profiles), ',' || profile || ',') > 0)
+    AND (CAST(sqlc.arg(regions) AS TEXT) = '' OR instr(sqlc.arg(regions), ',' || region || ',') > 0)
     AND pod_name like sqlc.arg(pod_name)
-ORDER BY event_time;
+    AND (CAST(sqlc.arg(namespace_name) AS TEXT) = '' OR namespace_name = sqlc.arg(namespace_name))
+    AND (CAST(sqlc.arg(container_name) AS TEXT) = '' OR container_name = sqlc.arg(container_name))
+    AND instr(CAST(sqlc.arg(excluded_containers) AS TEXT), ',' || container_name || ',') = 0
+    AND container_image LIKE sqlc.arg(container_image)
+    AND (CAST(sqlc.arg(host) AS TEXT) = '' OR host = sqlc.arg(host))
+    AND (CAST(sqlc.arg(stream) AS TEXT) = '' OR stream = sqlc.arg(stream))
+    AND (
+        SELECT COUNT(*) FROM json_each(logs.labels)
+        WHERE instr(CAST(sqlc.arg(labels) AS TEXT), ',' || json_each.key || '=' || json_each.value || ',') > 0
+    ) = CAST(sqlc.arg(nb_labels) AS INTEGER)
+    AND (CAST(sqlc.arg(grep) AS TEXT) = '' OR logs.log REGEXP sqlc.arg(grep))
+    AND (CAST(sqlc.arg(grep_v) AS TEXT) = '' OR logs.log NOT REGEXP sqlc.arg(grep_v))
+    AND (CAST(sqlc.arg(levels) AS TEXT) = '' OR instr(sqlc.arg(levels), ',' || level || ',') > 0)
+ORDER BY bm25(logs_fts), event_time, logs.id
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
 
 -- name: GetLogsOfPod :many
 SELECT * FROM logs 
@@ -35,3 +118,102 @@ WHERE event_time >= sqlc.arg(begindate) and event_time <= sqlc.arg(enddate)
 
 -- name: CountLogs :one
 SELECT COUNT(*) FROM logs;
+
+-- name: GetSyncWatermark :one
+-- The watermark recorded by the previous versions, without region and account, is used if there is none for them
+SELECT * FROM sync_watermarks
+WHERE profile = sqlc.arg(profile)
+    AND region IN (sqlc.arg(region), '')
+    AND account_id IN (sqlc.arg(account_id), '')
+    AND loggroup = sqlc.arg(loggroup)
+    AND pod_filter = sqlc.arg(pod_filter)
+    AND namespace_filter = sqlc.arg(namespace_filter)
+    AND container_filter = sqlc.arg(container_filter)
+    AND excluded_containers = sqlc.arg(excluded_containers)
+ORDER BY region DESC, account_id DESC
+LIMIT 1;
+
+-- name: UpsertSyncWatermark :exec
+INSERT INTO sync_watermarks (profile, region, account_id, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers, last_event_time, updated_at)
+VALUES (sqlc.arg(profile), sqlc.arg(region), sqlc.arg(account_id), sqlc.arg(loggroup), sqlc.arg(pod_filter), sqlc.arg(namespace_filter), sqlc.arg(container_filter), sqlc.arg(excluded_containers), sqlc.arg(last_event_time), sqlc.arg(updated_at))
+ON CONFLICT (profile, region, account_id, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers) DO UPDATE
+SET last_event_time = MAX(sync_watermarks.last_event_time, excluded.last_event_time),
+    updated_at = excluded.updated_at;
+
+-- name: InsertSyncWindow :exec
+INSERT INTO sync_windows (profile, region, account_id, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers, begin_time, end_time, event_count, finished_at)
+VALUES (sqlc.arg(profile), sqlc.arg(region), sqlc.arg(account_id), sqlc.arg(loggroup), sqlc.arg(pod_filter), sqlc.arg(namespace_filter), sqlc.arg(container_filter), sqlc.arg(excluded_containers), sqlc.arg(begin_time), sqlc.arg(end_time), sqlc.arg(event_count), sqlc.arg(finished_at));
+
+-- name: GetSyncWindows :many
+SELECT * FROM sync_windows
+ORDER BY profile, region, account_id, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers, begin_time;
+
+-- name: GetSyncWindowsOfLogGroup :many
+-- An empty region or account selects the windows of all the regions or accounts,
+-- the windows recorded by the previous versions, without region and account, are always selected
+SELECT * FROM sync_windows
+WHERE profile = sqlc.arg(profile)
+    AND (CAST(sqlc.arg(region) AS TEXT) = '' OR region IN (sqlc.arg(region), ''))
+    AND (CAST(sqlc.arg(account_id) AS TEXT) = '' OR account_id IN (sqlc.arg(account_id), ''))
+    AND loggroup = sqlc.arg(loggroup)
+ORDER BY begin_time;
+
+-- name: PurgeSyncWindows :exec
+DELETE FROM sync_windows;
+
+-- name: PurgeSyncWatermarks :exec
+DELETE FROM sync_watermarks;
+
+-- name: PurgeSpecificSyncWindows :exec
+-- Remove the windows whose pods may have been purged
+DELETE FROM sync_windows
+WHERE profile = sqlc.arg(profile)
+  AND loggroup = sqlc.arg(loggroup)
+  AND (pod_filter LIKE '%' || CAST(sqlc.arg(pod_name) AS TEXT) || '%' OR CAST(sqlc.arg(pod_name) AS TEXT) LIKE '%' || pod_filter || '%');
+
+-- name: PurgeSpecificSyncWatermarks :exec
+DELETE FROM sync_watermarks
+WHERE profile = sqlc.arg(profile)
+  AND loggroup = sqlc.arg(loggroup)
+  AND (pod_filter LIKE '%' || CAST(sqlc.arg(pod_name) AS TEXT) || '%' OR CAST(sqlc.arg(pod_name) AS TEXT) LIKE '%' || pod_filter || '%');
+
+-- name: GetLoggroups :many
+SELECT DISTINCT loggroup FROM logs ORDER BY loggroup;
+
+-- name: CountLogsOfLoggroup :one
+SELECT COUNT(*) FROM logs
+WHERE CAST(sqlc.arg(loggroup) AS TEXT) = '' OR loggroup = sqlc.arg(loggroup);
+
+-- name: GetLogAtRank :one
+-- Time and identifier of the log at the rank, the most recent log first
+SELECT event_time, id FROM logs
+WHERE CAST(sqlc.arg(loggroup) AS TEXT) = '' OR loggroup = sqlc.arg(loggroup)
+ORDER BY event_time DESC, id DESC
+LIMIT 1 OFFSET sqlc.arg(rank);
+
+-- name: DeleteLogsBefore :execrows
+-- Remove the logs up to the log (cutoff_time, cutoff_id), of all the loggroups if loggroup is empty
+DELETE FROM logs
+WHERE (CAST(sqlc.arg(loggroup) AS TEXT) = '' OR loggroup = sqlc.arg(loggroup))
+    AND (event_time < sqlc.arg(cutoff_time) OR (event_time = sqlc.arg(cutoff_time) AND id <= sqlc.arg(cutoff_id)));
+
+-- name: DeleteSyncWindowsBefore :exec
+-- Remove the windows whose logs have all been removed by the retention
+DELETE FROM sync_windows
+WHERE (CAST(sqlc.arg(loggroup) AS TEXT) = '' OR loggroup = sqlc.arg(loggroup))
+    AND end_time <= sqlc.arg(cutoff_time);
+
+-- name: TrimSyncWindowsBefore :exec
+-- The windows whose first logs have been removed by the retention now begin at the cutoff
+UPDATE sync_windows SET begin_time = sqlc.arg(cutoff_time)
+WHERE (CAST(sqlc.arg(loggroup) AS TEXT) = '' OR loggroup = sqlc.arg(loggroup))
+    AND begin_time < sqlc.arg(cutoff_time);
+
+-- name: GetLogsWithoutLevel :many
+SELECT id, log FROM logs
+WHERE level = '' AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(limit);
+
+-- name: SetLogLevel :exec
+UPDATE logs SET level = sqlc.arg(level) WHERE id = sqlc.arg(id);
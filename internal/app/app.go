@@ -2,19 +2,20 @@ package app
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"regexp"
-	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
-	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/dromara/carbon/v2"
 	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/parser"
 	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
 	"github.com/sgaunet/ekspodlogs/pkg/views"
 	"github.com/sirupsen/logrus"
@@ -24,6 +25,12 @@ import (
 const maxEventsAPICallPerSecond = 5000
 const maxLogGroupAPICALLPerSecond = 10
 
+// SyncResult summarises the events stored by a synchronisation
+type SyncResult struct {
+	EventCount    int       // Number of events saved in the database
+	LastEventTime time.Time // Time of the most recent event saved, zero if none
+}
+
 // App is the main structure of the application
 type App struct {
 	appLog               *logrus.Logger
@@ -34,6 +41,10 @@ type App struct {
 	clientCloudwatchlogs *cloudwatchlogs.Client
 	queries              *sqlite.Storage
 	tui                  *views.TerminalView
+	workers              int
+	filterPattern        string
+	parser               parser.Parser
+	accountID            string // AWS account saved with the logs, see GetIdentity
 }
 
 // New creates a new App
@@ -48,6 +59,8 @@ func New(cfg aws.Config, profileName string, db *sqlite.Storage, tui *views.Term
 		queries:              db,
 		tui:                  tui,
 		appLog:               logrus.New(),
+		workers:              1,
+		parser:               parser.Auto{},
 	}
 	return &app
 }
@@ -57,21 +70,42 @@ func (a *App) SetLogger(logger *logrus.Logger) {
 	a.appLog = logger
 }
 
-// PrintID prints AWS identity
+// SetWorkers sets the number of time shards fetched concurrently during a sync
+func (a *App) SetWorkers(workers int) {
+	a.workers = max(workers, 1)
+}
+
+// SetFilterPattern sets the CloudWatch filter pattern applied server side during a sync
+func (a *App) SetFilterPattern(pattern string) {
+	a.filterPattern = pattern
+}
+
+// SetParser sets the parser of the messages of the log events
+func (a *App) SetParser(p parser.Parser) {
+	a.parser = p
+}
+
+// Source returns the log group in the account and the region of the App
+// The account is known once GetIdentity has been called.
+func (a *App) Source(groupName string) sqlite.Source {
+	return sqlite.Source{Profile: a.profileName, Region: a.cfg.Region, AccountID: a.accountID, Loggroup: groupName}
+}
+
+// PrintID prints the AWS identity and the region used by the App
 // This function is used to test the AWS connection
-// Set a logger at debug level to see the output
-func (a *App) PrintID() error {
-	client := sts.NewFromConfig(a.cfg)
-	identity, err := client.GetCallerIdentity(
-		context.Background(),
-		&sts.GetCallerIdentityInput{},
-	)
+func (a *App) PrintID(w io.Writer) error {
+	identity, err := a.GetIdentity(context.Background())
 	if err != nil {
-		return fmt.Errorf("failed to get caller identity: %w", err)
+		return err
+	}
+	region := a.cfg.Region
+	if region == "" {
+		region = "unknown"
 	}
-	a.appLog.Debugf("Account: %s\n", aws.ToString(identity.Account))
-	a.appLog.Debugf("UserID: %s\n", aws.ToString(identity.UserId))
-	a.appLog.Debugf("ARN: %s\n", aws.ToString(identity.Arn))
+	fmt.Fprintf(w, "AWS identity: %s, region %s\n", identity, region)
+	a.appLog.Debugf("Account: %s\n", identity.Account)
+	a.appLog.Debugf("UserID: %s\n", identity.UserID)
+	a.appLog.Debugf("ARN: %s\n", identity.ARN)
 	return nil
 }
 
@@ -109,9 +143,9 @@ func (a *App) FindLogGroupAuto(ctx context.Context) (string, error) {
 }
 
 // PrintEvents prints events of a log group using FilterLogEvents for improved performance
-func (a *App) PrintEvents(ctx context.Context, groupName string, logStream string, startTime time.Time, endTime time.Time) error {
-	minTimeStampInMs := startTime.Unix() * 1000
-	maxTimeStampInMs := endTime.Unix() * 1000
+func (a *App) PrintEvents(ctx context.Context, groupName string, filter sqlite.LogFilter, startTime time.Time, endTime time.Time) (SyncResult, error) {
+	minTimeStampInMs := startTime.UnixMilli()
+	maxTimeStampInMs := endTime.UnixMilli()
 
 	// Add timeout to prevent indefinite hanging
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
@@ -120,113 +154,114 @@ func (a *App) PrintEvents(ctx context.Context, groupName string, logStream strin
 	a.tui.StartSpinnerRetrieveLogStreams()
 	
 	// Use FilterLogEvents instead of DescribeLogStreams + GetLogEvents for better performance
-	err := a.processEventsWithFilter(ctx, groupName, logStream, minTimeStampInMs, maxTimeStampInMs)
+	res, err := a.processEventsWithFilter(ctx, groupName, filter, minTimeStampInMs, maxTimeStampInMs)
 	
 	a.tui.StopSpinnerRetrieveLogStreams()
 	if err != nil {
-		return err
+		return res, err
 	}
-	return nil
+	return res, nil
+}
+
+// FetchEvents saves the events of a log group in the database without printing the progress
+// It is used to follow the logs, the events are printed from the database by the caller.
+func (a *App) FetchEvents(ctx context.Context, groupName string, filter sqlite.LogFilter, startTime time.Time, endTime time.Time) (SyncResult, error) {
+	return a.processEventsWithFilter(ctx, groupName, filter, startTime.UnixMilli(), endTime.UnixMilli())
 }
 
 // processEventsWithFilter uses FilterLogEvents API to retrieve and process log events efficiently
-func (a *App) processEventsWithFilter(ctx context.Context, groupName string, logStreamFilter string, minTimeStamp int64, maxTimeStamp int64) error {
-	// Set up FilterLogEvents input parameters
-	input := &cloudwatchlogs.FilterLogEventsInput{
-		LogGroupName: &groupName,
-		StartTime:    &minTimeStamp,
-		EndTime:      &maxTimeStamp,
-		Interleaved:  &[]bool{true}[0], // Sort events from multiple streams by timestamp
+// The period is split in time shards fetched concurrently by the workers, the events are
+// saved in the database by a single writer as SQLite handles only one writer at a time.
+func (a *App) processEventsWithFilter(ctx context.Context, groupName string, filter sqlite.LogFilter, minTimeStamp int64, maxTimeStamp int64) (SyncResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Keep the first error, the other ones are usually caused by the cancellation
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
 	}
-	
-	// Don't use LogStreamNamePrefix as EKS log stream names don't directly contain pod names
-	// Instead, we'll filter by pod name at the application level after parsing the JSON
-	a.appLog.Debugf("Note: Not using LogStreamNamePrefix filter, will filter by pod name in JSON content")
-	
-	// Create paginator for handling large result sets
-	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(a.clientCloudwatchlogs, input)
-	
-	eventCount := 0
-	pageCount := 0
-	filteredEventCount := 0
-	
-	a.appLog.Debugf("Starting FilterLogEvents for group %s with time range %d-%d", groupName, minTimeStamp, maxTimeStamp)
-	if logStreamFilter != "" {
-		a.appLog.Debugf("Will filter events by pod name containing: %s", logStreamFilter)
+
+	shards := splitPeriod(minTimeStamp, maxTimeStamp, a.workers)
+	a.tui.InitShards(len(shards))
+	a.appLog.Debugf("Starting FilterLogEvents for group %s with time range %d-%d in %d shards", groupName, minTimeStamp, maxTimeStamp, len(shards))
+	a.appLog.Debugf("Will filter events with: %s", filter)
+
+	pages := make(chan []sqlite.LogRecord, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.fetchShard(ctx, i, groupName, filter, shard, pages); err != nil {
+				fail(err)
+			}
+		}()
 	}
-	
-	// Process all pages of results
-	for paginator.HasMorePages() {
-		// Check context cancellation
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-		
-		// Rate limit the API call
-		if err := a.eventsRateLimit.Wait(ctx); err != nil {
-			return fmt.Errorf("rate limit wait error: %w", err)
+	go func() {
+		wg.Wait()
+		close(pages)
+	}()
+
+	res, err := a.writeEvents(ctx, groupName, pages)
+	if err != nil {
+		fail(err)
+	}
+	// Wait for the workers to stop
+	for range pages {
+	}
+	if firstErr != nil {
+		return res, firstErr
+	}
+
+	a.appLog.Debugf("Completed FilterLogEvents processing: %d events saved", res.EventCount)
+	return res, nil
+}
+
+// writeEvents saves the events received in the database until the channel is closed
+// Each page of events is saved in a single transaction.
+func (a *App) writeEvents(ctx context.Context, groupName string, pages <-chan []sqlite.LogRecord) (SyncResult, error) {
+	var res SyncResult
+	for page := range pages {
+		for i := range page {
+			page[i].Region = a.cfg.Region
+			page[i].AccountID = a.accountID
 		}
-		
-		// Get next page of events
-		output, err := paginator.NextPage(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to filter log events: %w", err)
+		if err := a.queries.AddLogs(ctx, a.profileName, groupName, page); err != nil {
+			return res, fmt.Errorf("failed to add logs: %w", err)
 		}
-		
-		pageCount++
-		a.appLog.Debugf("Processing page %d with %d events", pageCount, len(output.Events))
-		
-		// Update spinner with current progress
-		a.tui.UpdateSpinnerRetrieveLogStreamsWithText(fmt.Sprintf("Processing events... page %d, %d events found", pageCount, eventCount))
-		
-		// Process events from this page
-		for _, event := range output.Events {
-			eventCount++
-			
-			// Parse the log message as fluentDockerLog
-			var lineOfLog fluentDockerLog
-			err := json.Unmarshal([]byte(*event.Message), &lineOfLog)
-			if err != nil {
-				// Log the error but continue processing other events
-				a.appLog.Warnf("Failed to unmarshal log message (skipping): %v. Message: %s", err, *event.Message)
-				continue
-			}
-			
-			// Apply pod name filtering if specified (filter by actual pod name in parsed JSON)
-			if logStreamFilter != "" && !strings.Contains(lineOfLog.Kubernetes.PodName, logStreamFilter) {
-				continue
-			}
-			
-			filteredEventCount++
-			
-			// Convert timestamp and save to database
-			timeT := time.Unix(*event.Timestamp/1000, 0).UTC()
-			err = a.queries.AddLog(ctx, a.profileName, groupName, timeT, 
-				lineOfLog.Kubernetes.PodName, lineOfLog.Kubernetes.ContainerName, 
-				lineOfLog.Kubernetes.NamespaceName, lineOfLog.Log)
-			if err != nil {
-				return fmt.Errorf("failed to add log: %w", err)
-			}
-			
-			// Update spinner periodically to show progress
-			if filteredEventCount%100 == 0 { // Update UI every 100 events to avoid too frequent updates
-				a.tui.UpdateSpinnerRetrieveLogStreamsWithText(fmt.Sprintf("Processing events... %d saved to database", filteredEventCount))
+		res.EventCount += len(page)
+		for _, r := range page {
+			if r.EventTime.After(res.LastEventTime) {
+				res.LastEventTime = r.EventTime
 			}
 		}
+		a.tui.AddEventsSaved(len(page))
 	}
-	
-	a.appLog.Debugf("Completed FilterLogEvents processing: %d total events, %d matching filter, from %d pages", eventCount, filteredEventCount, pageCount)
-	return nil
+	return res, nil
 }
 
-// GetEvents returns events occured between two dates
-// This function is used to get events from the database
-func (a *App) GetEvents(ctx context.Context, profile string, groupName string, podName string, beginDate *carbon.Carbon, endDate *carbon.Carbon) ([]database.Log, error) {
-	res, err := a.queries.GetLogs(ctx, groupName, profile, podName, beginDate, endDate)
+// IterateSearchEvents calls fn for each event of the targets occured between two dates matching the full-text query, the most relevant first
+func (a *App) IterateSearchEvents(ctx context.Context, targets sqlite.Targets, filter sqlite.LogFilter, search string, beginDate *carbon.Carbon, endDate *carbon.Carbon, opts sqlite.IterateOptions, fn func(sqlite.SearchResult) error) error {
+	err := a.queries.IterateSearchResults(ctx, targets, filter, search, beginDate, endDate, opts, fn)
+	if errors.Is(err, sqlite.ErrInvalidSearch) {
+		return err // the message is for the user
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get logs: %w", err)
+		return fmt.Errorf("failed to search logs: %w", err)
 	}
-	return res, nil
+	return nil
+}
+
+// IterateEvents calls fn for each event of the targets occured between two dates
+// The events are streamed from the database, in chronological order unless opts.Reverse is set.
+func (a *App) IterateEvents(ctx context.Context, targets sqlite.Targets, filter sqlite.LogFilter, beginDate *carbon.Carbon, endDate *carbon.Carbon, opts sqlite.IterateOptions, fn func(database.Log) error) error {
+	if err := a.queries.IterateLogs(ctx, targets, filter, beginDate, endDate, opts, fn); err != nil {
+		return fmt.Errorf("failed to get logs: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+// FilterPattern returns the CloudWatch filter pattern selecting the events matching the filter
+// The pattern is applied by CloudWatch on the JSON events written by fluentd/fluent-bit,
+// so that only the matching events are downloaded. It returns an empty string if there is no filter.
+// Only the pod, namespace and container filters are translated, the other ones are checked after the download.
+func FilterPattern(filter sqlite.LogFilter) string {
+	var conditions []string
+	if filter.PodName != "" {
+		conditions = append(conditions, fmt.Sprintf("$.kubernetes.pod_name = %s", quotePatternValue("*"+filter.PodName+"*")))
+	}
+	if filter.Namespace != "" {
+		conditions = append(conditions, fmt.Sprintf("$.kubernetes.namespace_name = %s", quotePatternValue(filter.Namespace)))
+	}
+	if filter.Container != "" {
+		conditions = append(conditions, fmt.Sprintf("$.kubernetes.container_name = %s", quotePatternValue(filter.Container)))
+	}
+	for _, c := range filter.ExcludedContainers {
+		conditions = append(conditions, fmt.Sprintf("$.kubernetes.container_name != %s", quotePatternValue(c)))
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("{ %s }", strings.Join(conditions, " && "))
+}
+
+// quotePatternValue quotes a string value of a JSON filter pattern
+func quotePatternValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
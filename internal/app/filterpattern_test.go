@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+func TestFilterPattern(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter sqlite.LogFilter
+		want   string
+	}{
+		{"no filter", sqlite.LogFilter{}, ""},
+		{"pod name", sqlite.LogFilter{PodName: "api"}, `{ $.kubernetes.pod_name = "*api*" }`},
+		{"quotes are escaped", sqlite.LogFilter{PodName: `a"b`}, `{ $.kubernetes.pod_name = "*a\"b*" }`},
+		{
+			"all filters",
+			sqlite.LogFilter{PodName: "api", Namespace: "prod", ExcludedContainers: []string{"istio-proxy"}},
+			`{ $.kubernetes.pod_name = "*api*" && $.kubernetes.namespace_name = "prod" && $.kubernetes.container_name != "istio-proxy" }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilterPattern(tt.filter); got != tt.want {
+				t.Errorf("FilterPattern() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Identity is the AWS identity used to call the API
+type Identity struct {
+	Account string
+	UserID  string
+	ARN     string
+}
+
+// String returns the identity in a readable form, e.g. "role Admin (session alice) in account 123456789012"
+func (i Identity) String() string {
+	// arn:aws:sts::123456789012:assumed-role/Admin/alice, arn:aws:iam::123456789012:user/alice
+	parts := strings.SplitN(i.ARN, ":", 6)
+	if len(parts) != 6 {
+		return fmt.Sprintf("%s in account %s", i.ARN, i.Account)
+	}
+	resource := strings.Split(parts[5], "/")
+	switch {
+	case resource[0] == "assumed-role" && len(resource) == 3:
+		return fmt.Sprintf("role %s (session %s) in account %s", resource[1], resource[2], i.Account)
+	case resource[0] == "user" && len(resource) >= 2:
+		return fmt.Sprintf("user %s in account %s", resource[len(resource)-1], i.Account)
+	case resource[0] == "federated-user" && len(resource) == 2:
+		return fmt.Sprintf("federated user %s in account %s", resource[1], i.Account)
+	case resource[0] == "root":
+		return fmt.Sprintf("root user of account %s", i.Account)
+	}
+	return fmt.Sprintf("%s in account %s", i.ARN, i.Account)
+}
+
+// GetIdentity returns the AWS identity used by the App
+// Its account is saved with the logs synced afterwards.
+func (a *App) GetIdentity(ctx context.Context) (Identity, error) {
+	client := sts.NewFromConfig(a.cfg)
+	identity, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	a.accountID = aws.ToString(identity.Account)
+	return Identity{
+		Account: aws.ToString(identity.Account),
+		UserID:  aws.ToString(identity.UserId),
+		ARN:     aws.ToString(identity.Arn),
+	}, nil
+}
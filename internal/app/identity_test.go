@@ -0,0 +1,24 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestIdentityString(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:sts::123456789012:assumed-role/Admin/alice", "role Admin (session alice) in account 123456789012"},
+		{"arn:aws:iam::123456789012:user/ops/alice", "user alice in account 123456789012"},
+		{"arn:aws:sts::123456789012:federated-user/bob", "federated user bob in account 123456789012"},
+		{"arn:aws:iam::123456789012:root", "root user of account 123456789012"},
+		{"unknown", "unknown in account 123456789012"},
+	}
+	for _, tt := range tests {
+		id := Identity{Account: "123456789012", ARN: tt.arn}
+		if got := id.String(); got != tt.want {
+			t.Errorf("Identity{ARN: %q}.String() = %q, want %q", tt.arn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/sgaunet/ekspodlogs/pkg/level"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+// period is a time range in milliseconds since epoch, bounds included as in FilterLogEvents
+type period struct {
+	start int64
+	end   int64
+}
+
+// splitPeriod splits the period [start, end] in n contiguous shards which do not overlap
+func splitPeriod(start int64, end int64, n int) []period {
+	duration := end - start + 1
+	if n < 1 {
+		n = 1
+	}
+	if int64(n) > duration {
+		n = int(max(duration, 1))
+	}
+	size := duration / int64(n)
+
+	shards := make([]period, 0, n)
+	for i := range int64(n) {
+		p := period{start: start + i*size, end: start + (i+1)*size - 1}
+		if i == int64(n)-1 {
+			p.end = end
+		}
+		shards = append(shards, p)
+	}
+	return shards
+}
+
+// fetchShard retrieves the events of a shard and sends them page by page to the writer
+func (a *App) fetchShard(ctx context.Context, shard int, groupName string, filter sqlite.LogFilter, p period, out chan<- []sqlite.LogRecord) error {
+	// Set up FilterLogEvents input parameters
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: &groupName,
+		StartTime:    &p.start,
+		EndTime:      &p.end,
+		Interleaved:  &[]bool{true}[0], // Sort events from multiple streams by timestamp
+	}
+	// Filter events in CloudWatch to reduce the transfer, the filter is still checked below
+	if a.filterPattern != "" {
+		input.FilterPattern = &a.filterPattern
+	}
+
+	// Don't use LogStreamNamePrefix as EKS log stream names don't directly contain pod names
+	// Instead, we'll filter by pod name at the application level after parsing the JSON
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(a.clientCloudwatchlogs, input)
+
+	eventCount := 0
+	pageCount := 0
+	for paginator.HasMorePages() {
+		// Rate limit the API call, the limiter is shared by all the shards
+		if err := a.eventsRateLimit.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit wait error: %w", err)
+		}
+
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to filter log events: %w", err)
+		}
+		pageCount++
+		eventCount += len(output.Events)
+		a.appLog.Debugf("Shard %d: processing page %d with %d events", shard, pageCount, len(output.Events))
+
+		page := make([]sqlite.LogRecord, 0, len(output.Events))
+		for _, event := range output.Events {
+			// Parse the log message with the parser of the log router
+			parsed, ok := a.parser.Parse(aws.ToString(event.Message), aws.ToString(event.LogStreamName))
+			if !ok {
+				// Log the error but continue processing other events
+				a.appLog.Warnf("Failed to parse log message with the %s parser (skipping). Message: %s", a.parser.Name(), aws.ToString(event.Message))
+				continue
+			}
+
+			var ingestionTime time.Time
+			if event.IngestionTime != nil {
+				ingestionTime = time.UnixMilli(*event.IngestionTime).UTC()
+			}
+			record := sqlite.LogRecord{
+				EventID:        aws.ToString(event.EventId),
+				LogStreamName:  aws.ToString(event.LogStreamName),
+				EventTime:      time.UnixMilli(aws.ToInt64(event.Timestamp)).UTC(),
+				IngestionTime:  ingestionTime,
+				PodName:        parsed.PodName,
+				PodID:          parsed.PodID,
+				DockerID:       parsed.DockerID,
+				ContainerName:  parsed.ContainerName,
+				ContainerImage: parsed.ContainerImage,
+				NamespaceName:  parsed.NamespaceName,
+				Host:           parsed.Host,
+				Stream:         parsed.Stream,
+				Labels:         parsed.Labels,
+				Log:            parsed.Log,
+				Level:          level.Detect(parsed.Log),
+			}
+
+			// Apply the filter on the kubernetes metadata of the event
+			if !filter.Match(record) {
+				continue
+			}
+			page = append(page, record)
+		}
+
+		select {
+		case out <- page:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		a.tui.IncShardPages(shard)
+	}
+
+	a.tui.SetShardDone(shard)
+	a.appLog.Debugf("Shard %d completed: %d events from %d pages", shard, eventCount, pageCount)
+	return nil
+}
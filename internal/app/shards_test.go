@@ -0,0 +1,34 @@
+package app
+
+import "testing"
+
+func TestSplitPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int64
+		n          int
+		want       int
+	}{
+		{"one shard", 0, 999, 1, 1},
+		{"even split", 0, 999, 4, 4},
+		{"uneven split", 1000, 1999, 3, 3},
+		{"more shards than milliseconds", 0, 2, 10, 3},
+		{"invalid number of shards", 0, 999, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards := splitPeriod(tt.start, tt.end, tt.n)
+			if len(shards) != tt.want {
+				t.Fatalf("splitPeriod() returned %d shards, want %d", len(shards), tt.want)
+			}
+			if shards[0].start != tt.start || shards[len(shards)-1].end != tt.end {
+				t.Errorf("splitPeriod() = %v, does not cover [%d, %d]", shards, tt.start, tt.end)
+			}
+			for i := 1; i < len(shards); i++ {
+				if shards[i].start != shards[i-1].end+1 {
+					t.Errorf("splitPeriod() = %v, shards %d and %d are not contiguous", shards, i-1, i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package app
+
+import (
+	"errors"
+
+	"github.com/aws/smithy-go"
+)
+
+// throttlingCodes are the error codes returned by the AWS APIs when the requests are throttled
+var throttlingCodes = map[string]bool{
+	"ThrottlingException":      true,
+	"Throttling":               true,
+	"TooManyRequestsException": true,
+	"LimitExceededException":   true,
+	"RequestLimitExceeded":     true,
+}
+
+// IsThrottling returns true if the error is caused by the throttling of the AWS API
+// The SDK already retries the throttled requests a few times before returning such an error.
+func IsThrottling(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && throttlingCodes[apiErr.ErrorCode()]
+}
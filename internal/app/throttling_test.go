@@ -0,0 +1,29 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestIsThrottling(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"throttling", &smithy.GenericAPIError{Code: "ThrottlingException"}, true},
+		{"wrapped", fmt.Errorf("failed to filter log events: %w", &smithy.GenericAPIError{Code: "LimitExceededException"}), true},
+		{"other API error", &smithy.GenericAPIError{Code: "ResourceNotFoundException"}, false},
+		{"other error", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsThrottling(tt.err); got != tt.want {
+				t.Errorf("IsThrottling() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package config reads the configuration file of ekspodlogs.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of the configuration file
+type Config struct {
+	// Templates are the named output templates of the req command
+	Templates map[string]string `yaml:"templates"`
+	// Daemon is the configuration of the daemon command
+	Daemon Daemon `yaml:"daemon"`
+	// Retention limits the logs kept in the database
+	Retention Retention `yaml:"retention"`
+	// CurrentContext is the name of the context used when --context is not given
+	CurrentContext string `yaml:"current_context"`
+	// Contexts are the named sets of default values of the flags
+	Contexts map[string]Context `yaml:"contexts"`
+}
+
+// Context bundles the default values of the flags of a cluster, the flags take precedence
+type Context struct {
+	Profile           string   `yaml:"profile,omitempty"`            // SSO profile
+	Region            string   `yaml:"region,omitempty"`             // AWS region, instead of the one of the profile
+	RoleARNs          []string `yaml:"role_arns,omitempty"`          // Roles assumed in turn with the credentials of the profile
+	ExternalID        string   `yaml:"external_id,omitempty"`        // External ID of the last role
+	RoleSessionName   string   `yaml:"role_session_name,omitempty"`  // Name of the sessions of the roles
+	MFASerial         string   `yaml:"mfa_serial,omitempty"`         // MFA device of the first role
+	Group             string   `yaml:"group,omitempty"`              // Log group
+	Namespace         string   `yaml:"namespace,omitempty"`          // Namespace of the pods
+	Container         string   `yaml:"container,omitempty"`          // Name of the container
+	ExcludeContainers []string `yaml:"exclude_containers,omitempty"` // Names of the containers to ignore
+	Output            string   `yaml:"output,omitempty"`             // Output format of req
+	DB                string   `yaml:"db,omitempty"`                 // Database file, ~ is the home directory
+}
+
+// GetContext returns the context of the given name
+func (c *Config) GetContext(name string) (Context, error) {
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return Context{}, fmt.Errorf("context %q not found in the config file", name)
+	}
+	return ctx, nil
+}
+
+// Retention is the retention of the logs applied by the gc command, and by sync if AfterSync is set
+type Retention struct {
+	RetentionPolicy `yaml:",inline"`
+	MaxSize         string                     `yaml:"max_size"`   // Size of the database (e.g. 2GB)
+	Loggroups       map[string]RetentionPolicy `yaml:"loggroups"`  // Policy by loggroup, instead of the default one
+	AfterSync       bool                       `yaml:"after_sync"` // Apply the retention after each sync
+}
+
+// RetentionPolicy limits the logs kept for a loggroup
+type RetentionPolicy struct {
+	MaxAge  string `yaml:"max_age"`  // Age of the oldest logs kept (e.g. 30d)
+	MaxRows int64  `yaml:"max_rows"` // Number of logs kept
+}
+
+// Daemon lists the targets kept synchronised by the daemon command
+type Daemon struct {
+	Interval string   `yaml:"interval"` // Interval between two syncs of a target (e.g. 1m)
+	Keep     string   `yaml:"keep"`     // Period synchronised before now (e.g. 6h)
+	Targets  []Target `yaml:"targets"`
+}
+
+// Target is a connection to AWS, a log group and a filter on the pods synchronised by the daemon
+type Target struct {
+	Profile           string   `yaml:"profile"`
+	Region            string   `yaml:"region"`            // AWS region, instead of the one of the profile
+	RoleARNs          []string `yaml:"role_arns"`         // Roles assumed in turn with the credentials of the profile
+	ExternalID        string   `yaml:"external_id"`       // External ID of the last role
+	RoleSessionName   string   `yaml:"role_session_name"` // Name of the sessions of the roles
+	MFASerial         string   `yaml:"mfa_serial"`        // MFA device of the first role
+	Group             string   `yaml:"group"`
+	Pod               string   `yaml:"pod"`
+	Namespace         string   `yaml:"namespace"`
+	Container         string   `yaml:"container"`
+	ExcludeContainers []string `yaml:"exclude_containers"`
+}
+
+// DefaultPath returns the path of the configuration file:
+// $XDG_CONFIG_HOME/ekspodlogs/config.yaml, or ~/.config/ekspodlogs/config.yaml if XDG_CONFIG_HOME is not set
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "ekspodlogs", "config.yaml"), nil
+}
+
+// Load reads the configuration file, an empty configuration is returned if the file does not exist
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// SetCurrentContext writes the current context in the configuration file, the rest of the file is kept
+func SetCurrentContext(path, name string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse config file %s: not a mapping", path)
+	}
+	root := doc.Content[0]
+	found := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "current_context" {
+			root.Content[i+1].SetString(name)
+			found = true
+		}
+	}
+	if !found {
+		key := &yaml.Node{}
+		key.SetString("current_context")
+		value := &yaml.Node{}
+		value.SetString(name)
+		root.Content = append([]*yaml.Node{key, value}, root.Content...)
+	}
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
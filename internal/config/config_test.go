@@ -0,0 +1,134 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/ekspodlogs/internal/config"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("err returned by Load() for a missing file: %v", err)
+	}
+	if len(cfg.Templates) != 0 {
+		t.Errorf("Load() returned templates for a missing file")
+	}
+
+	content := "templates:\n  short: '{{.EventTime | date \"15:04:05\"}} {{.Log}}'\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = config.Load(path)
+	if err != nil {
+		t.Fatalf("err returned by Load(): %v", err)
+	}
+	if got, want := cfg.Templates["short"], `{{.EventTime | date "15:04:05"}} {{.Log}}`; got != want {
+		t.Errorf("Load() template = %q, want %q", got, want)
+	}
+
+	content = `daemon:
+  interval: 1m
+  keep: 6h
+  targets:
+    - profile: dev
+      region: eu-west-1
+      role_arns: [arn:aws:iam::210987654321:role/logs-reader]
+      external_id: "4711"
+      group: /aws/containerinsights/dev/application
+      namespace: shop
+      exclude_containers: [istio-proxy]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = config.Load(path)
+	if err != nil {
+		t.Fatalf("err returned by Load(): %v", err)
+	}
+	if cfg.Daemon.Interval != "1m" || cfg.Daemon.Keep != "6h" || len(cfg.Daemon.Targets) != 1 {
+		t.Fatalf("Load() daemon = %+v", cfg.Daemon)
+	}
+	if tg := cfg.Daemon.Targets[0]; tg.Profile != "dev" || tg.Region != "eu-west-1" || len(tg.RoleARNs) != 1 ||
+		tg.ExternalID != "4711" || tg.Namespace != "shop" || len(tg.ExcludeContainers) != 1 {
+		t.Errorf("Load() target = %+v", tg)
+	}
+
+	content = `retention:
+  max_age: 30d
+  max_size: 2GB
+  loggroups:
+    /aws/containerinsights/dev/application:
+      max_rows: 100000
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = config.Load(path)
+	if err != nil {
+		t.Fatalf("err returned by Load(): %v", err)
+	}
+	r := cfg.Retention
+	if r.MaxAge != "30d" || r.MaxSize != "2GB" || r.Loggroups["/aws/containerinsights/dev/application"].MaxRows != 100000 {
+		t.Errorf("Load() retention = %+v", r)
+	}
+
+	if err := os.WriteFile(path, []byte("templates: ["), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(path); err == nil {
+		t.Errorf("Load() returned no error for an invalid file")
+	}
+}
+
+func TestContexts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `# clusters
+current_context: dev
+contexts:
+  dev:
+    profile: dev
+    region: eu-west-1
+    group: /aws/containerinsights/dev/application
+    exclude_containers: [istio-proxy]
+  prod:
+    profile: prod # read only
+    output: ndjson
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("err returned by Load(): %v", err)
+	}
+	ctx, err := cfg.GetContext(cfg.CurrentContext)
+	if err != nil {
+		t.Fatalf("err returned by GetContext(): %v", err)
+	}
+	if ctx.Profile != "dev" || ctx.Region != "eu-west-1" || len(ctx.ExcludeContainers) != 1 {
+		t.Errorf("GetContext() = %+v", ctx)
+	}
+	if _, err := cfg.GetContext("staging"); err == nil {
+		t.Errorf("GetContext() returned no error for an unknown context")
+	}
+
+	if err := config.SetCurrentContext(path, "prod"); err != nil {
+		t.Fatalf("err returned by SetCurrentContext(): %v", err)
+	}
+	cfg, err = config.Load(path)
+	if err != nil {
+		t.Fatalf("err returned by Load(): %v", err)
+	}
+	if cfg.CurrentContext != "prod" || cfg.Contexts["prod"].Output != "ndjson" {
+		t.Errorf("Load() after SetCurrentContext() = %+v", cfg)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# read only") {
+		t.Errorf("SetCurrentContext() removed the comments:\n%s", data)
+	}
+}
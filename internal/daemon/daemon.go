@@ -0,0 +1,119 @@
+// Package daemon keeps a set of targets synchronised on an interval.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/internal/app"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBackoff is the longest delay before syncing again a throttled target
+const maxBackoff = 15 * time.Minute
+
+// Target is a connection to AWS, a log group and a filter on the pods to keep synchronised
+type Target struct {
+	Profile         string
+	Region          string   // Region, the one of the profile if empty
+	RoleARNs        []string // Roles assumed in turn with the credentials of the profile
+	ExternalID      string   // External ID of the last role
+	RoleSessionName string   // Name of the sessions of the roles
+	MFASerial       string   // MFA device of the first role
+	Group           string
+	Filter          sqlite.LogFilter
+}
+
+func (t Target) String() string {
+	s := fmt.Sprintf("profile=%q", t.Profile)
+	if t.Region != "" {
+		s += fmt.Sprintf(" region=%q", t.Region)
+	}
+	if len(t.RoleARNs) != 0 {
+		s += fmt.Sprintf(" roles=%q", t.RoleARNs)
+	}
+	return s + fmt.Sprintf(" group=%q filter=%s", t.Group, t.Filter)
+}
+
+// SyncFunc synchronises a target
+type SyncFunc func(ctx context.Context, t Target) error
+
+// Daemon syncs each target every interval until its context is canceled
+// The targets are synced one at a time, SQLite handles only one writer at a time.
+type Daemon struct {
+	targets  []Target
+	interval time.Duration
+	sync     SyncFunc
+	log      *logrus.Logger
+	now      func() time.Time
+}
+
+// New creates a daemon syncing the targets every interval with the function sync
+func New(targets []Target, interval time.Duration, sync SyncFunc, log *logrus.Logger) *Daemon {
+	return &Daemon{
+		targets:  targets,
+		interval: interval,
+		sync:     sync,
+		log:      log,
+		now:      time.Now,
+	}
+}
+
+// Run syncs the targets until the context is canceled
+// A target throttled by AWS is synced again after a jittered exponential backoff,
+// a target failing for another reason is synced again at the next interval.
+func (d *Daemon) Run(ctx context.Context) error {
+	next := make([]time.Time, len(d.targets))
+	failures := make([]int, len(d.targets))
+	for {
+		// Wait for the target due the first
+		i := 0
+		for j := range next {
+			if next[j].Before(next[i]) {
+				i = j
+			}
+		}
+		timer := time.NewTimer(max(next[i].Sub(d.now()), 0))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		t := d.targets[i]
+		start := d.now()
+		err := d.sync(ctx, t)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err == nil:
+			failures[i] = 0
+			next[i] = start.Add(d.interval)
+			d.log.Debugf("Synced %s in %s", t, d.now().Sub(start).Round(time.Millisecond))
+		case app.IsThrottling(err):
+			failures[i]++
+			delay := Backoff(failures[i], d.interval, maxBackoff)
+			next[i] = d.now().Add(delay)
+			d.log.Warnf("Sync of %s throttled, retrying in %s: %v", t, delay.Round(time.Second), err)
+		default:
+			next[i] = start.Add(d.interval)
+			d.log.Errorf("Failed to sync %s: %v", t, err)
+		}
+	}
+}
+
+// Backoff returns the delay before the next attempt after n consecutive failures
+// The delay doubles at each failure up to maxDelay, a random jitter of up to half
+// the delay avoids synchronised retries.
+func Backoff(n int, base time.Duration, maxDelay time.Duration) time.Duration {
+	d := base
+	for i := 1; i < n && d < maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, maxDelay)
+	return d/2 + rand.N(d/2+1)
+}
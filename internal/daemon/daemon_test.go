@@ -0,0 +1,79 @@
+package daemon_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/internal/daemon"
+	"github.com/sirupsen/logrus"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		n        int
+		min, max time.Duration
+	}{
+		{1, 30 * time.Second, time.Minute},
+		{2, time.Minute, 2 * time.Minute},
+		{3, 2 * time.Minute, 4 * time.Minute},
+		{10, 5 * time.Minute, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if d := daemon.Backoff(tt.n, time.Minute, 10*time.Minute); d < tt.min || d > tt.max {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", tt.n, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var synced, failed atomic.Int32
+	sync := func(ctx context.Context, tg daemon.Target) error {
+		if tg.Group == "failing" {
+			failed.Add(1)
+			return errors.New("connection refused")
+		}
+		synced.Add(1)
+		return nil
+	}
+	targets := []daemon.Target{{Profile: "dev", Group: "ok"}, {Profile: "dev", Group: "failing"}}
+	d := daemon.New(targets, 10*time.Millisecond, sync, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := d.Run(ctx); err != nil {
+		t.Fatalf("err returned by Run(): %v", err)
+	}
+	// A failing target does not prevent the other ones to be synced
+	if synced.Load() < 3 || failed.Load() < 3 {
+		t.Errorf("Run() synced the targets %d and %d times, want several times", synced.Load(), failed.Load())
+	}
+}
+
+func TestAcquirePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.pid")
+	p, err := daemon.AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("err returned by AcquirePIDFile(): %v", err)
+	}
+	if _, err := daemon.AcquirePIDFile(path); err == nil {
+		t.Errorf("AcquirePIDFile() returned no error for a locked file")
+	}
+	if err := p.Release(); err != nil {
+		t.Fatalf("err returned by Release(): %v", err)
+	}
+	p, err = daemon.AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("err returned by AcquirePIDFile() after Release(): %v", err)
+	}
+	p.Release()
+}
@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// PIDFile is a file holding the PID of the daemon, locked while the daemon runs
+type PIDFile struct {
+	path string
+	file *os.File
+}
+
+// AcquirePIDFile locks the file and writes the PID of the process in it
+// An error is returned if the file is locked by another daemon.
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pid file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		defer f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			data, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("another daemon is running (pid %s, pid file %s)", strings.TrimSpace(string(data)), path)
+		}
+		return nil, fmt.Errorf("failed to lock pid file: %w", err)
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write pid file: %w", err)
+	}
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write pid file: %w", err)
+	}
+	return &PIDFile{path: path, file: f}, nil
+}
+
+// Release removes the file and releases the lock
+func (p *PIDFile) Release() error {
+	if err := os.Remove(p.path); err != nil {
+		p.file.Close()
+		return fmt.Errorf("failed to remove pid file: %w", err)
+	}
+	if err := p.file.Close(); err != nil {
+		return fmt.Errorf("failed to close pid file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package coverage computes which periods of time have been synchronised in the local database.
+package coverage
+
+import (
+	"sort"
+	"time"
+)
+
+// Tolerance is the maximum distance between two intervals to consider them contiguous
+// Dates are given with a precision of one second, so a sync ending at 00:59:59 and
+// another one beginning at 01:00:00 cover the whole period.
+const Tolerance = time.Second
+
+// Interval is a period of time, bounds included
+type Interval struct {
+	Begin time.Time
+	End   time.Time
+}
+
+// Merge returns the union of the intervals, sorted and without overlaps
+func Merge(intervals []Interval) []Interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+	sorted := make([]Interval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Begin.Before(sorted[j].Begin)
+	})
+
+	merged := []Interval{sorted[0]}
+	for _, in := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if in.Begin.After(last.End.Add(Tolerance)) {
+			merged = append(merged, in)
+			continue
+		}
+		if in.End.After(last.End) {
+			last.End = in.End
+		}
+	}
+	return merged
+}
+
+// Gaps returns the parts of the period [begin, end] that are not covered by the intervals
+func Gaps(intervals []Interval, begin, end time.Time) []Interval {
+	var gaps []Interval
+	cursor := begin
+	for _, in := range Merge(intervals) {
+		if !in.End.After(cursor) {
+			continue
+		}
+		if in.Begin.After(end) {
+			break
+		}
+		if in.Begin.After(cursor.Add(Tolerance)) {
+			gaps = append(gaps, Interval{Begin: cursor, End: in.Begin})
+		}
+		cursor = in.End
+	}
+	if end.After(cursor) {
+		gaps = append(gaps, Interval{Begin: cursor, End: end})
+	}
+	return gaps
+}
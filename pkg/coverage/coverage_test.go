@@ -0,0 +1,60 @@
+package coverage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/pkg/coverage"
+)
+
+func at(hour, minute, second int) time.Time {
+	return time.Date(2025, 3, 1, hour, minute, second, 0, time.UTC)
+}
+
+func TestMerge(t *testing.T) {
+	merged := coverage.Merge([]coverage.Interval{
+		{Begin: at(13, 0, 0), End: at(14, 0, 0)},
+		{Begin: at(10, 0, 0), End: at(10, 59, 59)},
+		{Begin: at(11, 0, 0), End: at(11, 30, 0)},
+		{Begin: at(11, 15, 0), End: at(11, 20, 0)},
+	})
+	want := []coverage.Interval{
+		{Begin: at(10, 0, 0), End: at(11, 30, 0)},
+		{Begin: at(13, 0, 0), End: at(14, 0, 0)},
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("Merge() returned %d intervals, want %d: %v", len(merged), len(want), merged)
+	}
+	for i := range want {
+		if !merged[i].Begin.Equal(want[i].Begin) || !merged[i].End.Equal(want[i].End) {
+			t.Errorf("Merge()[%d] = %v, want %v", i, merged[i], want[i])
+		}
+	}
+}
+
+func TestGaps(t *testing.T) {
+	intervals := []coverage.Interval{
+		{Begin: at(10, 0, 0), End: at(11, 0, 0)},
+		{Begin: at(12, 0, 0), End: at(13, 0, 0)},
+	}
+
+	gaps := coverage.Gaps(intervals, at(10, 30, 0), at(12, 30, 0))
+	if len(gaps) != 1 || !gaps[0].Begin.Equal(at(11, 0, 0)) || !gaps[0].End.Equal(at(12, 0, 0)) {
+		t.Errorf("Gaps() = %v, want one gap from 11:00 to 12:00", gaps)
+	}
+
+	gaps = coverage.Gaps(intervals, at(9, 0, 0), at(14, 0, 0))
+	if len(gaps) != 3 {
+		t.Errorf("Gaps() = %v, want 3 gaps", gaps)
+	}
+
+	gaps = coverage.Gaps(intervals, at(12, 10, 0), at(12, 50, 0))
+	if len(gaps) != 0 {
+		t.Errorf("Gaps() = %v, want no gap", gaps)
+	}
+
+	gaps = coverage.Gaps(nil, at(12, 10, 0), at(12, 50, 0))
+	if len(gaps) != 1 {
+		t.Errorf("Gaps() = %v, want the whole period", gaps)
+	}
+}
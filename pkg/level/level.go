@@ -0,0 +1,143 @@
+// Package level detects the severity level of log messages.
+package level
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Level is the severity of a log message, from the least to the most severe
+type Level string
+
+const (
+	Unknown Level = ""
+	Trace   Level = "trace"
+	Debug   Level = "debug"
+	Info    Level = "info"
+	Warn    Level = "warn"
+	Error   Level = "error"
+	Fatal   Level = "fatal"
+)
+
+// levels are the known levels, from the least to the most severe
+var levels = []Level{Trace, Debug, Info, Warn, Error, Fatal}
+
+// Parse returns the level named name, common aliases like warning or critical are accepted
+func Parse(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "trace", "trc":
+		return Trace, nil
+	case "debug", "dbg":
+		return Debug, nil
+	case "info", "information", "inf", "notice":
+		return Info, nil
+	case "warn", "warning", "wrn":
+		return Warn, nil
+	case "error", "err", "eror":
+		return Error, nil
+	case "fatal", "critical", "crit", "panic", "emerg", "alert":
+		return Fatal, nil
+	}
+	return Unknown, fmt.Errorf("unknown level %q (expected trace, debug, info, warn, error or fatal)", name)
+}
+
+// AtLeast returns the levels as severe as l or more
+func (l Level) AtLeast() []Level {
+	i := slices.Index(levels, l)
+	if i < 0 {
+		return nil
+	}
+	return slices.Clone(levels[i:])
+}
+
+// IsAtLeast returns true if l is as severe as min or more
+func (l Level) IsAtLeast(min Level) bool {
+	return slices.Contains(min.AtLeast(), l)
+}
+
+// Fields of JSON and logfmt messages holding the level
+var levelKeys = []string{"level", "severity", "lvl", "loglevel"}
+
+// Case-insensitive regex patterns for different log levels
+var (
+	logfmtPattern = regexp.MustCompile(`(?i)\b(?:level|severity|lvl|loglevel)="?(\w+)"?`)
+	debugPattern  = regexp.MustCompile(`(?i)\b(debug|trace)\b|\[debug\]|\[trace\]|debug:|trace:`)
+	infoPattern   = regexp.MustCompile(`(?i)\b(info|information)\b|\[info\]|info:`)
+	warnPattern   = regexp.MustCompile(`(?i)\b(warn|warning)\b|\[warn\]|\[warning\]|warn:|warning:`)
+	errorPattern  = regexp.MustCompile(`(?i)\b(error|err)\b|\[error\]|\[err\]|error:|err:`)
+	fatalPattern  = regexp.MustCompile(`(?i)\b(fatal|critical|panic)\b|\[fatal\]|\[critical\]|fatal:|critical:|panic:`)
+)
+
+// Detect returns the level of a log message
+// The level field of JSON (level, severity, ...) and logfmt (level=...) messages is used when present,
+// the level is guessed from the keywords of the message otherwise.
+func Detect(log string) Level {
+	if l, ok := fromJSON(log); ok {
+		return l
+	}
+	if m := logfmtPattern.FindStringSubmatch(log); m != nil {
+		if l, err := Parse(m[1]); err == nil {
+			return l
+		}
+	}
+	switch {
+	case fatalPattern.MatchString(log):
+		return Fatal
+	case errorPattern.MatchString(log):
+		return Error
+	case warnPattern.MatchString(log):
+		return Warn
+	case infoPattern.MatchString(log):
+		return Info
+	case debugPattern.MatchString(log):
+		return Debug
+	}
+	return Unknown
+}
+
+// fromJSON returns the level field of a JSON message
+func fromJSON(log string) (Level, bool) {
+	log = strings.TrimSpace(log)
+	if !strings.HasPrefix(log, "{") {
+		return Unknown, false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(log), &fields); err != nil {
+		return Unknown, false
+	}
+	for _, key := range levelKeys {
+		switch v := fields[key].(type) {
+		case string:
+			if l, err := Parse(v); err == nil {
+				return l, true
+			}
+		case float64:
+			// numeric levels of bunyan and pino
+			if l, ok := fromNumber(v); ok {
+				return l, true
+			}
+		}
+	}
+	return Unknown, false
+}
+
+func fromNumber(n float64) (Level, bool) {
+	switch {
+	case n >= 60:
+		return Fatal, true
+	case n >= 50:
+		return Error, true
+	case n >= 40:
+		return Warn, true
+	case n >= 30:
+		return Info, true
+	case n >= 20:
+		return Debug, true
+	case n >= 10:
+		return Trace, true
+	}
+	return Unknown, false
+}
@@ -0,0 +1,47 @@
+package level_test
+
+import (
+	"testing"
+
+	"github.com/sgaunet/ekspodlogs/pkg/level"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		log  string
+		want level.Level
+	}{
+		{`{"level":"warning","msg":"disk almost full"}`, level.Warn},
+		{`{"severity":"ERROR","message":"payment failed"}`, level.Error},
+		{`{"level":30,"msg":"listening"}`, level.Info},
+		{`{"msg":"error while connecting"}`, level.Error},
+		{`time=2025-03-01T10:00:00Z level=debug msg="cache miss"`, level.Debug},
+		{`ts=1 lvl=crit msg=oom`, level.Fatal},
+		{`[WARN] retrying in 5s`, level.Warn},
+		{`panic: runtime error: index out of range`, level.Fatal},
+		{`INFO server started`, level.Info},
+		{`GET /health 200`, level.Unknown},
+	}
+	for _, tt := range tests {
+		if got := level.Detect(tt.log); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.log, got, tt.want)
+		}
+	}
+}
+
+func TestIsAtLeast(t *testing.T) {
+	tests := []struct {
+		l, min level.Level
+		want   bool
+	}{
+		{level.Error, level.Warn, true},
+		{level.Warn, level.Warn, true},
+		{level.Info, level.Warn, false},
+		{level.Unknown, level.Warn, false},
+	}
+	for _, tt := range tests {
+		if got := tt.l.IsAtLeast(tt.min); got != tt.want {
+			t.Errorf("%q.IsAtLeast(%q) = %v, want %v", tt.l, tt.min, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package parser parses the messages of the log events written in CloudWatch by the log routers of an EKS cluster.
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Event is a log event parsed from a CloudWatch message
+type Event struct {
+	Log            string
+	Stream         string // stdout or stderr
+	PodName        string
+	PodID          string
+	NamespaceName  string
+	ContainerName  string
+	ContainerImage string
+	DockerID       string
+	Host           string
+	Labels         map[string]string
+}
+
+// Parser parses the message of a CloudWatch log event
+type Parser interface {
+	// Name returns the name of the parser, as given to the --parser option
+	Name() string
+	// Parse returns the event of the message, the boolean is false if the message has not the expected format
+	// logStreamName is the name of the CloudWatch log stream of the event.
+	Parse(message string, logStreamName string) (Event, bool)
+}
+
+// Names of the parsers
+const (
+	AutoName      = "auto"
+	FluentdName   = "fluentd"
+	FluentBitName = "fluentbit"
+	RawName       = "raw"
+)
+
+// ByName returns the parser named name
+func ByName(name string) (Parser, error) {
+	switch strings.ToLower(name) {
+	case "", AutoName:
+		return Auto{}, nil
+	case FluentdName:
+		return Fluentd{}, nil
+	case FluentBitName, "fluent-bit":
+		return FluentBit{}, nil
+	case RawName:
+		return Raw{}, nil
+	}
+	return nil, fmt.Errorf("unknown parser %q (expected %s, %s, %s or %s)", name, AutoName, FluentdName, FluentBitName, RawName)
+}
+
+// Auto detects the format of each message
+// JSON messages with kubernetes metadata are parsed as Fluent Bit messages when they contain
+// fields specific to Fluent Bit, as fluentd messages otherwise. Other messages are raw messages.
+type Auto struct{}
+
+func (Auto) Name() string { return AutoName }
+
+func (Auto) Parse(message string, logStreamName string) (Event, bool) {
+	var probe struct {
+		Partial    *string `json:"_p"`
+		Kubernetes *struct {
+			PodName  string `json:"pod_name"`
+			DockerID string `json:"docker_id"`
+		} `json:"kubernetes"`
+	}
+	if !strings.HasPrefix(strings.TrimSpace(message), "{") || json.Unmarshal([]byte(message), &probe) != nil ||
+		probe.Kubernetes == nil || probe.Kubernetes.PodName == "" {
+		return Raw{}.Parse(message, logStreamName)
+	}
+	if probe.Partial != nil || probe.Kubernetes.DockerID != "" {
+		return FluentBit{}.Parse(message, logStreamName)
+	}
+	return Fluentd{}.Parse(message, logStreamName)
+}
+
+// Fluentd parses the messages of the legacy fluentd daemonset of Container Insights
+type Fluentd struct{}
+
+// Format of the fluentd Docker logs
+type fluentDockerLog struct {
+	Log        string          `json:"log"`
+	Stream     string          `json:"stream"`
+	Docker     dockerInfos     `json:"docker"`
+	Kubernetes kubernetesInfos `json:"kubernetes"`
+}
+
+type dockerInfos struct {
+	ContainerID string `json:"container_id"`
+}
+
+// Subpart of fluent Docker logs
+type kubernetesInfos struct {
+	PodName        string            `json:"pod_name"`
+	PodID          string            `json:"pod_id"`
+	ContainerImage string            `json:"container_image"`
+	ContainerName  string            `json:"container_name"`
+	NamespaceName  string            `json:"namespace_name"`
+	Host           string            `json:"host"`
+	DockerID       string            `json:"docker_id"`
+	Labels         map[string]string `json:"labels"`
+}
+
+func (Fluentd) Name() string { return FluentdName }
+
+func (Fluentd) Parse(message string, _ string) (Event, bool) {
+	var line fluentDockerLog
+	if err := json.Unmarshal([]byte(message), &line); err != nil {
+		return Event{}, false
+	}
+	ev := line.Kubernetes.event(line.Log, line.Stream, message)
+	// The ID may be given by kubernetes.docker_id instead of docker.container_id
+	if line.Docker.ContainerID != "" {
+		ev.DockerID = line.Docker.ContainerID
+	}
+	return ev, true
+}
+
+// FluentBit parses the messages of the Fluent Bit daemonset of Container Insights,
+// and of the Fluent Bit log router of Fargate
+type FluentBit struct{}
+
+// Format of the Fluent Bit logs
+type fluentBitLog struct {
+	Log        string          `json:"log"`
+	Stream     string          `json:"stream"`
+	Kubernetes kubernetesInfos `json:"kubernetes"`
+}
+
+func (FluentBit) Name() string { return FluentBitName }
+
+func (FluentBit) Parse(message string, _ string) (Event, bool) {
+	var line fluentBitLog
+	if err := json.Unmarshal([]byte(message), &line); err != nil {
+		return Event{}, false
+	}
+	return line.Kubernetes.event(line.Log, line.Stream, message), true
+}
+
+// event returns the event of the log, the whole message is kept when the log field is missing
+func (k kubernetesInfos) event(log, stream, message string) Event {
+	if log == "" {
+		log = message
+	}
+	return Event{
+		Log:            log,
+		Stream:         stream,
+		PodName:        k.PodName,
+		PodID:          k.PodID,
+		NamespaceName:  k.NamespaceName,
+		ContainerName:  k.ContainerName,
+		ContainerImage: k.ContainerImage,
+		DockerID:       k.DockerID,
+		Host:           k.Host,
+		Labels:         k.Labels,
+	}
+}
+
+// Raw keeps the message as is
+// The pod, namespace and container are read from the log stream name when it is the
+// name of the container log file (…var.log.containers.<pod>_<namespace>_<container>-<docker id>.log).
+type Raw struct{}
+
+var containerLogFile = regexp.MustCompile(`containers\.([^_]+)_([^_]+)_(.+)-([0-9a-f]{64})\.log$`)
+
+func (Raw) Name() string { return RawName }
+
+func (Raw) Parse(message string, logStreamName string) (Event, bool) {
+	ev := Event{Log: message}
+	if m := containerLogFile.FindStringSubmatch(logStreamName); m != nil {
+		ev.PodName, ev.NamespaceName, ev.ContainerName, ev.DockerID = m[1], m[2], m[3], m[4]
+	}
+	return ev, true
+}
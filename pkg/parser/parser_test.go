@@ -0,0 +1,87 @@
+package parser_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sgaunet/ekspodlogs/pkg/parser"
+)
+
+const (
+	fluentdMessage   = `{"log":"hello\n","stream":"stdout","docker":{"container_id":"abc"},"kubernetes":{"container_name":"api","namespace_name":"prod","pod_name":"api-7d9f","container_image":"api:1.2","host":"ip-10-0-0-1","labels":{"app":"api"}}}`
+	fluentBitMessage = `{"time":"2025-03-01T10:00:00.123Z","stream":"stderr","_p":"F","log":"boom","kubernetes":{"pod_name":"api-7d9f","namespace_name":"prod","pod_id":"42","host":"ip-10-0-0-2","container_name":"api","docker_id":"def","container_image":"api:1.3","labels":{"app":"api","tier":"web"}}}`
+	rawStream        = "ip-10-0-0-1.application.var.log.containers.api-7d9f_prod_api-" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef.log"
+)
+
+func TestAuto(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		stream  string
+		want    parser.Event
+	}{
+		{
+			"fluentd", fluentdMessage, "",
+			parser.Event{Log: "hello\n", Stream: "stdout", PodName: "api-7d9f", NamespaceName: "prod", ContainerName: "api", ContainerImage: "api:1.2", DockerID: "abc", Host: "ip-10-0-0-1"},
+		},
+		{
+			"fluent bit", fluentBitMessage, "",
+			parser.Event{Log: "boom", Stream: "stderr", PodName: "api-7d9f", PodID: "42", NamespaceName: "prod", ContainerName: "api", ContainerImage: "api:1.3", DockerID: "def", Host: "ip-10-0-0-2"},
+		},
+		{
+			"raw with container log stream", "plain text", rawStream,
+			parser.Event{Log: "plain text", PodName: "api-7d9f", NamespaceName: "prod", ContainerName: "api", DockerID: strings.Repeat("0123456789abcdef", 4)},
+		},
+		{
+			"json without kubernetes", `{"level":"info"}`, "stream",
+			parser.Event{Log: `{"level":"info"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parser.Auto{}.Parse(tt.message, tt.stream)
+			if !ok {
+				t.Fatalf("Parse() did not recognise the message")
+			}
+			got.Labels = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFluentBitLabels(t *testing.T) {
+	ev, ok := parser.FluentBit{}.Parse(fluentBitMessage, "")
+	if !ok {
+		t.Fatalf("Parse() did not recognise the message")
+	}
+	if len(ev.Labels) != 2 || ev.Labels["tier"] != "web" {
+		t.Errorf("Labels = %v, want app=api and tier=web", ev.Labels)
+	}
+}
+
+func TestFluentdDockerID(t *testing.T) {
+	message := `{"log":"hello","kubernetes":{"pod_name":"api-7d9f","docker_id":"abc"}}`
+	if ev, _ := (parser.Fluentd{}).Parse(message, ""); ev.DockerID != "abc" {
+		t.Errorf("DockerID = %q, want the docker_id of kubernetes abc", ev.DockerID)
+	}
+	if ev, _ := (parser.Fluentd{}).Parse(fluentdMessage, ""); ev.DockerID != "abc" {
+		t.Errorf("DockerID = %q, want the container_id of docker abc", ev.DockerID)
+	}
+}
+
+func TestByName(t *testing.T) {
+	for _, name := range []string{"", "auto", "fluentd", "fluentbit", "fluent-bit", "raw"} {
+		if _, err := parser.ByName(name); err != nil {
+			t.Errorf("ByName(%q) returned an error: %v", name, err)
+		}
+	}
+	if _, err := parser.ByName("syslog"); err == nil {
+		t.Errorf("ByName() did not return an error for an unknown parser")
+	}
+	if _, ok := (parser.Fluentd{}).Parse("not json", ""); ok {
+		t.Errorf("Fluentd.Parse() recognised a message which is not JSON")
+	}
+}
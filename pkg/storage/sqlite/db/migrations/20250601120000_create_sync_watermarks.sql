@@ -0,0 +1,14 @@
+-- migrate:up
+
+CREATE TABLE sync_watermarks (
+    profile character varying(50) NOT NULL,
+    loggroup character varying(255) NOT NULL,
+    pod_filter character varying(255) NOT NULL,
+    last_event_time timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY (profile, loggroup, pod_filter)
+);
+
+-- migrate:down
+
+DROP TABLE sync_watermarks;
@@ -0,0 +1,18 @@
+-- migrate:up
+
+CREATE TABLE sync_windows (
+    id integer PRIMARY KEY AUTOINCREMENT NOT NULL,
+    profile character varying(50) NOT NULL,
+    loggroup character varying(255) NOT NULL,
+    pod_filter character varying(255) NOT NULL,
+    begin_time timestamp NOT NULL,
+    end_time timestamp NOT NULL,
+    event_count integer NOT NULL,
+    finished_at timestamp NOT NULL
+);
+
+CREATE INDEX sync_windows_profile_loggroup_idx ON sync_windows (profile, loggroup);
+
+-- migrate:down
+
+DROP TABLE sync_windows;
@@ -0,0 +1,13 @@
+-- migrate:up
+
+ALTER TABLE logs ADD COLUMN event_id character varying(255) NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN log_stream_name character varying(512) NOT NULL DEFAULT '';
+
+-- Logs synced before this migration have no event id, they are not deduplicated
+CREATE UNIQUE INDEX logs_event_id_idx ON logs (profile, loggroup, event_id) WHERE event_id <> '';
+
+-- migrate:down
+
+DROP INDEX logs_event_id_idx;
+ALTER TABLE logs DROP COLUMN log_stream_name;
+ALTER TABLE logs DROP COLUMN event_id;
@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE logs ADD COLUMN ingestion_time timestamp;
+
+-- migrate:down
+
+ALTER TABLE logs DROP COLUMN ingestion_time;
@@ -0,0 +1,42 @@
+-- migrate:up
+
+ALTER TABLE sync_windows ADD COLUMN namespace_filter character varying(255) NOT NULL DEFAULT '';
+ALTER TABLE sync_windows ADD COLUMN container_filter character varying(255) NOT NULL DEFAULT '';
+ALTER TABLE sync_windows ADD COLUMN excluded_containers character varying(1024) NOT NULL DEFAULT '';
+
+-- The filters are part of the primary key, the table has to be rebuilt
+CREATE TABLE sync_watermarks_new (
+    profile character varying(50) NOT NULL,
+    loggroup character varying(255) NOT NULL,
+    pod_filter character varying(255) NOT NULL,
+    namespace_filter character varying(255) NOT NULL DEFAULT '',
+    container_filter character varying(255) NOT NULL DEFAULT '',
+    excluded_containers character varying(1024) NOT NULL DEFAULT '',
+    last_event_time timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY (profile, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers)
+);
+INSERT INTO sync_watermarks_new (profile, loggroup, pod_filter, last_event_time, updated_at)
+SELECT profile, loggroup, pod_filter, last_event_time, updated_at FROM sync_watermarks;
+DROP TABLE sync_watermarks;
+ALTER TABLE sync_watermarks_new RENAME TO sync_watermarks;
+
+-- migrate:down
+
+CREATE TABLE sync_watermarks_old (
+    profile character varying(50) NOT NULL,
+    loggroup character varying(255) NOT NULL,
+    pod_filter character varying(255) NOT NULL,
+    last_event_time timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY (profile, loggroup, pod_filter)
+);
+INSERT OR IGNORE INTO sync_watermarks_old (profile, loggroup, pod_filter, last_event_time, updated_at)
+SELECT profile, loggroup, pod_filter, last_event_time, updated_at FROM sync_watermarks
+WHERE namespace_filter = '' AND container_filter = '' AND excluded_containers = '';
+DROP TABLE sync_watermarks;
+ALTER TABLE sync_watermarks_old RENAME TO sync_watermarks;
+
+ALTER TABLE sync_windows DROP COLUMN excluded_containers;
+ALTER TABLE sync_windows DROP COLUMN container_filter;
+ALTER TABLE sync_windows DROP COLUMN namespace_filter;
@@ -0,0 +1,8 @@
+-- migrate:up
+
+-- ID of the container given by the Docker or containerd runtime
+ALTER TABLE logs ADD COLUMN docker_id character varying(128) NOT NULL DEFAULT '';
+
+-- migrate:down
+
+ALTER TABLE logs DROP COLUMN docker_id;
@@ -0,0 +1,16 @@
+-- migrate:up
+
+ALTER TABLE logs ADD COLUMN container_image character varying(512) NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN pod_id character varying(64) NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN host character varying(255) NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN stream character varying(16) NOT NULL DEFAULT '';
+-- Labels of the pod as a JSON object
+ALTER TABLE logs ADD COLUMN labels TEXT NOT NULL DEFAULT '{}';
+
+-- migrate:down
+
+ALTER TABLE logs DROP COLUMN labels;
+ALTER TABLE logs DROP COLUMN stream;
+ALTER TABLE logs DROP COLUMN host;
+ALTER TABLE logs DROP COLUMN pod_id;
+ALTER TABLE logs DROP COLUMN container_image;
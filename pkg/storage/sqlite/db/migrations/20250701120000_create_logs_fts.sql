@@ -0,0 +1,29 @@
+-- migrate:up
+
+-- Full-text index of the log column, kept in sync with the logs table by the triggers below
+-- It requires SQLite compiled with FTS5 (build tag sqlite_fts5 of go-sqlite3, enforced by fts5.go).
+CREATE VIRTUAL TABLE logs_fts USING fts5(log, content='logs', content_rowid='id');
+
+CREATE TRIGGER logs_fts_ad AFTER DELETE ON logs BEGIN
+    INSERT INTO logs_fts (logs_fts, rowid, log) VALUES ('delete', old.id, old.log);
+END;
+
+CREATE TRIGGER logs_fts_ai AFTER INSERT ON logs BEGIN
+    INSERT INTO logs_fts (rowid, log) VALUES (new.id, new.log);
+END;
+
+-- Only the updates of the log change the index, not the ones of the level or the metadata
+CREATE TRIGGER logs_fts_au AFTER UPDATE OF log ON logs BEGIN
+    INSERT INTO logs_fts (logs_fts, rowid, log) VALUES ('delete', old.id, old.log);
+    INSERT INTO logs_fts (rowid, log) VALUES (new.id, new.log);
+END;
+
+-- Index the logs already stored
+INSERT INTO logs_fts (logs_fts) VALUES ('rebuild');
+
+-- migrate:down
+
+DROP TRIGGER logs_fts_au;
+DROP TRIGGER logs_fts_ai;
+DROP TRIGGER logs_fts_ad;
+DROP TABLE logs_fts;
@@ -0,0 +1,11 @@
+-- migrate:up
+
+-- Level detected during the sync (trace, debug, info, warn, error, fatal), empty if unknown
+ALTER TABLE logs ADD COLUMN level character varying(8) NOT NULL DEFAULT '';
+
+CREATE INDEX logs_level_idx ON logs (level);
+
+-- migrate:down
+
+DROP INDEX logs_level_idx;
+ALTER TABLE logs DROP COLUMN level;
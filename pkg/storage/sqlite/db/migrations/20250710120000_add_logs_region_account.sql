@@ -0,0 +1,64 @@
+-- migrate:up
+
+-- AWS region and account of the log group, empty for the logs synced by the previous versions
+ALTER TABLE logs ADD COLUMN region character varying(32) NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN account_id character varying(12) NOT NULL DEFAULT '';
+
+-- The same event ID may be found in the log groups of the same name of several accounts or regions
+DROP INDEX logs_event_id_idx;
+CREATE UNIQUE INDEX logs_event_id_idx ON logs (profile, region, account_id, loggroup, event_id) WHERE event_id <> '';
+
+ALTER TABLE sync_windows ADD COLUMN region character varying(32) NOT NULL DEFAULT '';
+ALTER TABLE sync_windows ADD COLUMN account_id character varying(12) NOT NULL DEFAULT '';
+
+-- The region and the account are part of the primary key, the table has to be rebuilt
+CREATE TABLE sync_watermarks_new (
+    profile character varying(50) NOT NULL,
+    region character varying(32) NOT NULL DEFAULT '',
+    account_id character varying(12) NOT NULL DEFAULT '',
+    loggroup character varying(255) NOT NULL,
+    pod_filter character varying(255) NOT NULL,
+    namespace_filter character varying(255) NOT NULL DEFAULT '',
+    container_filter character varying(255) NOT NULL DEFAULT '',
+    excluded_containers character varying(1024) NOT NULL DEFAULT '',
+    last_event_time timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY (profile, region, account_id, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers)
+);
+INSERT INTO sync_watermarks_new (profile, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers, last_event_time, updated_at)
+SELECT profile, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers, last_event_time, updated_at FROM sync_watermarks;
+DROP TABLE sync_watermarks;
+ALTER TABLE sync_watermarks_new RENAME TO sync_watermarks;
+
+-- migrate:down
+
+-- The watermarks of the accounts and regions are merged, the earliest one is kept
+CREATE TABLE sync_watermarks_old (
+    profile character varying(50) NOT NULL,
+    loggroup character varying(255) NOT NULL,
+    pod_filter character varying(255) NOT NULL,
+    namespace_filter character varying(255) NOT NULL DEFAULT '',
+    container_filter character varying(255) NOT NULL DEFAULT '',
+    excluded_containers character varying(1024) NOT NULL DEFAULT '',
+    last_event_time timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY (profile, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers)
+);
+INSERT INTO sync_watermarks_old (profile, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers, last_event_time, updated_at)
+SELECT profile, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers, MIN(last_event_time), MAX(updated_at) FROM sync_watermarks
+GROUP BY profile, loggroup, pod_filter, namespace_filter, container_filter, excluded_containers;
+DROP TABLE sync_watermarks;
+ALTER TABLE sync_watermarks_old RENAME TO sync_watermarks;
+
+ALTER TABLE sync_windows DROP COLUMN account_id;
+ALTER TABLE sync_windows DROP COLUMN region;
+
+-- The events saved for several accounts or regions are kept once
+DROP INDEX logs_event_id_idx;
+DELETE FROM logs WHERE event_id <> '' AND id NOT IN (
+    SELECT MIN(id) FROM logs WHERE event_id <> '' GROUP BY profile, loggroup, event_id
+);
+CREATE UNIQUE INDEX logs_event_id_idx ON logs (profile, loggroup, event_id) WHERE event_id <> '';
+
+ALTER TABLE logs DROP COLUMN account_id;
+ALTER TABLE logs DROP COLUMN region;
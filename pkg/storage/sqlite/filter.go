@@ -0,0 +1,233 @@
+package sqlite
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/level"
+)
+
+// LogFilter selects the logs of some pods, the empty fields match all the logs
+type LogFilter struct {
+	PodName            string      // Substring of the pod name
+	Namespace          string      // Namespace of the pod
+	Container          string      // Name of the container
+	ExcludedContainers []string    // Names of the containers to ignore
+	Image              string      // Substring of the container image
+	Node               string      // Host of the pod
+	Stream             string      // stdout or stderr
+	Labels             []string    // Labels of the pod, as key=value
+	Grep               []string    // Regular expressions, the log has to match one of them
+	GrepV              []string    // Regular expressions, the log must not match any of them
+	Level              level.Level // Minimum level of the logs
+}
+
+// FilterOfSyncWindow returns the filter used by the sync of the window
+func FilterOfSyncWindow(w database.SyncWindow) LogFilter {
+	return LogFilter{
+		PodName:            w.PodFilter,
+		Namespace:          w.NamespaceFilter,
+		Container:          w.ContainerFilter,
+		ExcludedContainers: splitExcludedContainers(w.ExcludedContainers),
+	}
+}
+
+// SyncFilter returns the part of the filter recorded with the synchronised periods: the pods and the containers
+// The other fields only select the logs read from the database, the events they reject have to be saved.
+func (f LogFilter) SyncFilter() LogFilter {
+	return LogFilter{
+		PodName:            f.PodName,
+		Namespace:          f.Namespace,
+		Container:          f.Container,
+		ExcludedContainers: f.ExcludedContainers,
+	}
+}
+
+// IsEmpty returns true if the filter selects all the logs
+func (f LogFilter) IsEmpty() bool {
+	return f.PodName == "" && f.Namespace == "" && f.Container == "" && len(f.ExcludedContainers) == 0 &&
+		f.Image == "" && f.Node == "" && f.Stream == "" && len(f.Labels) == 0 &&
+		len(f.Grep) == 0 && len(f.GrepV) == 0 && f.Level == level.Unknown
+}
+
+// Validate returns an error if a regular expression of the filter is invalid
+func (f LogFilter) Validate() error {
+	for _, p := range append(slices.Clone(f.Grep), f.GrepV...) {
+		if _, err := compileRegexp(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match returns true if the record is selected by the filter
+func (f LogFilter) Match(r LogRecord) bool {
+	if !strings.Contains(r.PodName, f.PodName) ||
+		(f.Namespace != "" && f.Namespace != r.NamespaceName) ||
+		(f.Container != "" && f.Container != r.ContainerName) ||
+		slices.Contains(f.ExcludedContainers, r.ContainerName) ||
+		!strings.Contains(r.ContainerImage, f.Image) ||
+		(f.Node != "" && f.Node != r.Host) ||
+		(f.Stream != "" && f.Stream != r.Stream) {
+		return false
+	}
+	for _, l := range f.labels() {
+		key, value, _ := strings.Cut(l, "=")
+		if v, ok := r.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	if f.Level != level.Unknown && !r.Level.IsAtLeast(f.Level) {
+		return false
+	}
+	if grep := JoinRegexps(f.Grep); grep != "" {
+		if re, err := compileRegexp(grep); err != nil || !re.MatchString(r.Log) {
+			return false
+		}
+	}
+	if grepV := JoinRegexps(f.GrepV); grepV != "" {
+		if re, err := compileRegexp(grepV); err != nil || re.MatchString(r.Log) {
+			return false
+		}
+	}
+	return true
+}
+
+// Covers returns true if all the logs selected by other are also selected by f
+// A period synchronised with f is then fully synchronised for other.
+func (f LogFilter) Covers(other LogFilter) bool {
+	if !strings.Contains(other.PodName, f.PodName) {
+		return false
+	}
+	if f.Namespace != "" && f.Namespace != other.Namespace {
+		return false
+	}
+	if f.Container != "" && f.Container != other.Container {
+		return false
+	}
+	for _, c := range f.ExcludedContainers {
+		// other selects another container, or excludes this one too
+		if (other.Container != "" && other.Container != c) || slices.Contains(other.ExcludedContainers, c) {
+			continue
+		}
+		return false
+	}
+	if !strings.Contains(other.Image, f.Image) ||
+		(f.Node != "" && f.Node != other.Node) ||
+		(f.Stream != "" && f.Stream != other.Stream) {
+		return false
+	}
+	for _, l := range f.labels() {
+		if !slices.Contains(other.labels(), l) {
+			return false
+		}
+	}
+	// other has to match a subset of the regular expressions of f and exclude at least the same ones
+	if len(f.Grep) > 0 && (len(other.Grep) == 0 || slices.ContainsFunc(other.Grep, func(g string) bool {
+		return !slices.Contains(f.Grep, g)
+	})) {
+		return false
+	}
+	for _, v := range f.GrepV {
+		if !slices.Contains(other.GrepV, v) {
+			return false
+		}
+	}
+	if f.Level != level.Unknown && !other.Level.IsAtLeast(f.Level) {
+		return false
+	}
+	return true
+}
+
+// String returns a human readable description of the filter
+func (f LogFilter) String() string {
+	var parts []string
+	if f.PodName != "" {
+		parts = append(parts, fmt.Sprintf("pod=*%s*", f.PodName))
+	}
+	if f.Namespace != "" {
+		parts = append(parts, "namespace="+f.Namespace)
+	}
+	if f.Container != "" {
+		parts = append(parts, "container="+f.Container)
+	}
+	for _, c := range f.ExcludedContainers {
+		parts = append(parts, "container!="+c)
+	}
+	if f.Image != "" {
+		parts = append(parts, fmt.Sprintf("image=*%s*", f.Image))
+	}
+	if f.Node != "" {
+		parts = append(parts, "node="+f.Node)
+	}
+	if f.Stream != "" {
+		parts = append(parts, "stream="+f.Stream)
+	}
+	for _, l := range f.labels() {
+		parts = append(parts, "label:"+l)
+	}
+	for _, g := range f.Grep {
+		parts = append(parts, fmt.Sprintf("log=~/%s/", g))
+	}
+	for _, g := range f.GrepV {
+		parts = append(parts, fmt.Sprintf("log!~/%s/", g))
+	}
+	if f.Level != level.Unknown {
+		parts = append(parts, "level>="+string(f.Level))
+	}
+	if len(parts) == 0 {
+		return "(all pods)"
+	}
+	return strings.Join(parts, " ")
+}
+
+// excludedContainersKey returns the excluded containers as stored in the database, sorted and comma separated
+func (f LogFilter) excludedContainersKey() string {
+	excluded := slices.Clone(f.ExcludedContainers)
+	slices.Sort(excluded)
+	return strings.Join(slices.Compact(excluded), ",")
+}
+
+// excludedContainersParam returns the excluded containers as expected by the queries: ",a,b,"
+func (f LogFilter) excludedContainersParam() string {
+	if key := f.excludedContainersKey(); key != "" {
+		return "," + key + ","
+	}
+	return ""
+}
+
+// levelsParam returns the levels selected by the filter as expected by the queries: ",warn,error,fatal,"
+func (f LogFilter) levelsParam() string {
+	if f.Level == level.Unknown {
+		return ""
+	}
+	var levels []string
+	for _, l := range f.Level.AtLeast() {
+		levels = append(levels, string(l))
+	}
+	return "," + strings.Join(levels, ",") + ","
+}
+
+// labels returns the labels sorted and without duplicates
+func (f LogFilter) labels() []string {
+	labels := slices.Clone(f.Labels)
+	slices.Sort(labels)
+	return slices.Compact(labels)
+}
+
+// labelsParam returns the labels as expected by the queries: ",key1=value1,key2=value2,"
+func (f LogFilter) labelsParam() string {
+	if labels := f.labels(); len(labels) > 0 {
+		return "," + strings.Join(labels, ",") + ","
+	}
+	return ""
+}
+
+func splitExcludedContainers(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return strings.Split(key, ",")
+}
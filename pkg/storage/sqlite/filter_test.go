@@ -0,0 +1,101 @@
+package sqlite_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sgaunet/ekspodlogs/pkg/level"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+func TestLogFilterMatch(t *testing.T) {
+	f := sqlite.LogFilter{PodName: "api", Namespace: "prod", ExcludedContainers: []string{"istio-proxy"}}
+	tests := []struct {
+		pod, namespace, container string
+		want                      bool
+	}{
+		{"api-7d9f", "prod", "api", true},
+		{"api-7d9f", "prod", "istio-proxy", false},
+		{"api-7d9f", "dev", "api", false},
+		{"worker-5c8b", "prod", "worker", false},
+	}
+	for _, tt := range tests {
+		r := sqlite.LogRecord{PodName: tt.pod, NamespaceName: tt.namespace, ContainerName: tt.container}
+		if got := f.Match(r); got != tt.want {
+			t.Errorf("Match(%q, %q, %q) = %v, want %v", tt.pod, tt.namespace, tt.container, got, tt.want)
+		}
+	}
+
+	f = sqlite.LogFilter{Labels: []string{"app=api"}, Stream: "stderr"}
+	r := sqlite.LogRecord{Stream: "stderr", Labels: map[string]string{"app": "api", "tier": "web"}}
+	if !f.Match(r) {
+		t.Errorf("Match() = false for a record with the label and the stream")
+	}
+	r.Labels["app"] = "worker"
+	if f.Match(r) {
+		t.Errorf("Match() = true for a record with another label value")
+	}
+
+	f = sqlite.LogFilter{Grep: []string{"timeout", "refused"}, GrepV: []string{"^DEBUG"}}
+	for log, want := range map[string]bool{
+		"ERROR connection refused": true,
+		"WARN timeout":             true,
+		"DEBUG timeout":            false,
+		"INFO ok":                  false,
+	} {
+		if got := f.Match(sqlite.LogRecord{Log: log}); got != want {
+			t.Errorf("Match(%q) = %v, want %v", log, got, want)
+		}
+	}
+}
+
+func TestLogFilterCovers(t *testing.T) {
+	tests := []struct {
+		name   string
+		synced sqlite.LogFilter
+		req    sqlite.LogFilter
+		want   bool
+	}{
+		{"all pods", sqlite.LogFilter{}, sqlite.LogFilter{PodName: "api", Namespace: "prod"}, true},
+		{"more specific pod", sqlite.LogFilter{PodName: "api"}, sqlite.LogFilter{PodName: "api-7d9f"}, true},
+		{"other pod", sqlite.LogFilter{PodName: "api"}, sqlite.LogFilter{PodName: "worker"}, false},
+		{"other namespace", sqlite.LogFilter{Namespace: "prod"}, sqlite.LogFilter{Namespace: "dev"}, false},
+		{"namespace not requested", sqlite.LogFilter{Namespace: "prod"}, sqlite.LogFilter{}, false},
+		{"excluded container requested", sqlite.LogFilter{ExcludedContainers: []string{"istio-proxy"}}, sqlite.LogFilter{}, false},
+		{"excluded container excluded", sqlite.LogFilter{ExcludedContainers: []string{"istio-proxy"}}, sqlite.LogFilter{ExcludedContainers: []string{"istio-proxy"}}, true},
+		{"other container requested", sqlite.LogFilter{ExcludedContainers: []string{"istio-proxy"}}, sqlite.LogFilter{Container: "api"}, true},
+		{"grep requested", sqlite.LogFilter{}, sqlite.LogFilter{Grep: []string{"error"}, GrepV: []string{"debug"}}, true},
+		{"grep not requested", sqlite.LogFilter{Grep: []string{"error"}}, sqlite.LogFilter{}, false},
+		{"level requested", sqlite.LogFilter{}, sqlite.LogFilter{Level: level.Warn}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.synced.Covers(tt.req); got != tt.want {
+				t.Errorf("Covers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogFilterSyncFilter(t *testing.T) {
+	f := sqlite.LogFilter{
+		PodName:            "api",
+		Namespace:          "prod",
+		Container:          "app",
+		ExcludedContainers: []string{"istio-proxy"},
+		Image:              "nginx",
+		Node:               "ip-10-0-0-1",
+		Stream:             "stderr",
+		Labels:             []string{"app=api"},
+		Grep:               []string{"error"},
+		GrepV:              []string{"debug"},
+		Level:              level.Warn,
+	}
+	want := sqlite.LogFilter{PodName: "api", Namespace: "prod", Container: "app", ExcludedContainers: []string{"istio-proxy"}}
+	if got := f.SyncFilter(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SyncFilter() = %+v, want %+v", got, want)
+	}
+	if !f.SyncFilter().Covers(f) {
+		t.Errorf("SyncFilter() does not cover the filter %+v", f)
+	}
+}
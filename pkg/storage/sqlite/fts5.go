@@ -0,0 +1,7 @@
+//go:build !sqlite_fts5
+
+package sqlite
+
+// The full-text index of the logs needs SQLite compiled with FTS5, the migrations fail without it.
+// Build with: go build -tags sqlite_fts5
+var _ = ekspodlogs_must_be_built_with_the_tag_sqlite_fts5
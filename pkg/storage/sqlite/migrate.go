@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/amacneil/dbmate/v2/pkg/dbmate"
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/level"
+)
+
+// migrationsDir is the directory of the migrations in the embedded file system
+const migrationsDir = "db/migrations"
+
+// levelMigration is the version of the migration adding the level column
+// The level of the logs already stored is detected once it is applied.
+const levelMigration = "20250705120000"
+
+// Migration is a migration of the schema of the database
+type Migration struct {
+	Version string
+	Name    string
+	Applied bool
+}
+
+// migrator returns the dbmate instance applying the embedded migrations, its output is written to log
+func (s *Storage) migrator(log io.Writer) *dbmate.DB {
+	u, _ := url.Parse(fmt.Sprintf("sqlite3://%s", s.dbFile))
+	db := dbmate.New(u)
+	db.FS = fs
+	db.MigrationsDir = []string{migrationsDir}
+	db.AutoDumpSchema = false
+	db.Log = log
+	return db
+}
+
+// LatestSchemaVersion returns the version of the last migration known by this version of ekspodlogs
+func LatestSchemaVersion() (string, error) {
+	entries, err := fs.ReadDir(migrationsDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migrations: %w", err)
+	}
+	var latest string
+	for _, e := range entries {
+		if version, _, ok := strings.Cut(e.Name(), "_"); ok && version > latest {
+			latest = version
+		}
+	}
+	return latest, nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the database, empty if none
+func (s *Storage) SchemaVersion(ctx context.Context) (string, error) {
+	var exists int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&exists)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the schema version: %w", err)
+	}
+	if exists == 0 {
+		return "", nil
+	}
+	var version string
+	err = s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), '') FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Migrations returns the migrations known by this version of ekspodlogs and whether they are applied
+func (s *Storage) Migrations() ([]Migration, error) {
+	found, err := s.migrator(io.Discard).FindMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find migrations: %w", err)
+	}
+	migrations := make([]Migration, 0, len(found))
+	for _, m := range found {
+		migrations = append(migrations, Migration{
+			Version: m.Version,
+			Name:    strings.TrimSuffix(path.Base(m.FileName), ".sql"),
+			Applied: m.Applied,
+		})
+	}
+	return migrations, nil
+}
+
+// PendingMigrations returns the migrations not applied yet
+// An error is returned if the database has been migrated by a more recent version of ekspodlogs.
+func (s *Storage) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if current > latest {
+		return nil, fmt.Errorf("the schema of the database (version %s) is more recent than the one of this version of ekspodlogs (version %s), upgrade ekspodlogs", current, latest)
+	}
+	migrations, err := s.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if !m.Applied {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations, the progress is written to log
+func (s *Storage) Migrate(log io.Writer) error {
+	ctx := context.Background()
+	pending, err := s.PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.migrator(log).CreateAndMigrate(); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", withFTS5Hint(err))
+	}
+	if slices.ContainsFunc(pending, func(m Migration) bool { return m.Version == levelMigration }) {
+		return s.detectLevels(ctx)
+	}
+	return nil
+}
+
+// detectLevels detects the level of the logs stored without level
+func (s *Storage) detectLevels(ctx context.Context) error {
+	var afterID int64
+	for {
+		rows, err := s.queries.GetLogsWithoutLevel(ctx, database.GetLogsWithoutLevelParams{AfterID: afterID, Limit: pageSize})
+		if err != nil {
+			return fmt.Errorf("failed to get logs without level: %w", err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		err = s.inTx(ctx, func(q *database.Queries) error {
+			for _, r := range rows {
+				if l := level.Detect(r.Log); l != level.Unknown {
+					if err := q.SetLogLevel(ctx, database.SetLogLevelParams{Level: string(l), ID: r.ID}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set the level of the logs: %w", err)
+		}
+		afterID = rows[len(rows)-1].ID
+	}
+}
+
+// withFTS5Hint explains the error of a migration creating the full-text index with a SQLite built without FTS5
+func withFTS5Hint(err error) error {
+	if strings.Contains(err.Error(), "no such module: fts5") {
+		return fmt.Errorf("%w (ekspodlogs has to be built with: go build -tags sqlite_fts5)", err)
+	}
+	return err
+}
+
+// Rollback reverts the last migration applied, the progress is written to log
+func (s *Storage) Rollback(log io.Writer) error {
+	if err := s.migrator(log).Rollback(); err != nil {
+		return fmt.Errorf("failed to rollback database: %w", err)
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the database to dest, an existing file is replaced
+func (s *Storage) Backup(ctx context.Context, dest string) error {
+	if err := os.Remove(dest); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove the previous backup: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", dest); err != nil {
+		return fmt.Errorf("failed to backup database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package sqlite_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, _ := sqlite.NewStorage(filepath.Join(dir, "db.sqlite3"))
+	defer s.Close()
+
+	if version, _ := s.SchemaVersion(ctx); version != "" {
+		t.Errorf("SchemaVersion() of a new database = %q, want empty", version)
+	}
+	if err := s.Migrate(io.Discard); err != nil {
+		t.Fatalf("err returned by Migrate(): %v", err.Error())
+	}
+	latest, err := sqlite.LatestSchemaVersion()
+	if err != nil {
+		t.Fatalf("err returned by LatestSchemaVersion(): %v", err.Error())
+	}
+	if version, _ := s.SchemaVersion(ctx); version != latest {
+		t.Errorf("SchemaVersion() after Migrate() = %q, want %q", version, latest)
+	}
+	if pending, _ := s.PendingMigrations(ctx); len(pending) != 0 {
+		t.Errorf("PendingMigrations() after Migrate() = %v, want none", pending)
+	}
+
+	backup := filepath.Join(dir, "db.sqlite3.backup")
+	if err := s.Backup(ctx, backup); err != nil {
+		t.Fatalf("err returned by Backup(): %v", err.Error())
+	}
+	if _, err := os.Stat(backup); err != nil {
+		t.Errorf("backup not found: %v", err.Error())
+	}
+
+	if err := s.Rollback(io.Discard); err != nil {
+		t.Fatalf("err returned by Rollback(): %v", err.Error())
+	}
+	pending, err := s.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("err returned by PendingMigrations(): %v", err.Error())
+	}
+	if len(pending) != 1 || pending[0].Version != latest {
+		t.Errorf("PendingMigrations() after Rollback() = %v, want the last migration", pending)
+	}
+
+	// The backup is a database at the latest version
+	b, _ := sqlite.NewStorage(backup)
+	defer b.Close()
+	if version, _ := b.SchemaVersion(ctx); version != latest {
+		t.Errorf("SchemaVersion() of the backup = %q, want %q", version, latest)
+	}
+}
+
+func TestMigrateDetectsLevels(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	// Logs stored before the level column
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []sqlite.LogRecord{
+		{EventID: "1", EventTime: begin, Log: `{"level":"error","msg":"boom"}`},
+		{EventID: "2", EventTime: begin.Add(time.Second), Log: "plain text"},
+	}
+	if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+	for {
+		version, err := s.SchemaVersion(ctx)
+		if err != nil {
+			t.Fatalf("err returned by SchemaVersion(): %v", err.Error())
+		}
+		if version < "20250705120000" {
+			break
+		}
+		if err := s.Rollback(io.Discard); err != nil {
+			t.Fatalf("err returned by Rollback(): %v", err.Error())
+		}
+	}
+
+	if err := s.Migrate(io.Discard); err != nil {
+		t.Fatalf("err returned by Migrate(): %v", err.Error())
+	}
+	logs, err := s.GetLogs(ctx, "group", "dev", sqlite.LogFilter{}, carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("err returned by GetLogs(): %v", err.Error())
+	}
+	if len(logs) != 2 || logs[0].Level != "error" || logs[1].Level != "" {
+		t.Errorf("GetLogs() after Migrate() = %v, want the levels error and unknown", logs)
+	}
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the go-sqlite3 driver with the REGEXP function
+const driverName = "sqlite3_ekspodlogs"
+
+// regexps caches the compiled regular expressions used by the REGEXP function
+var regexps sync.Map
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			// X REGEXP Y calls regexp(Y, X)
+			return conn.RegisterFunc("regexp", regexpMatch, true)
+		},
+	})
+}
+
+func regexpMatch(pattern string, s string) (bool, error) {
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+	}
+	regexps.Store(pattern, re)
+	return re, nil
+}
+
+// JoinRegexps returns a regular expression matching any of the patterns, or an empty string if there is none
+func JoinRegexps(patterns []string) string {
+	if len(patterns) == 0 {
+		return ""
+	}
+	groups := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		groups = append(groups, "(?:"+p+")")
+	}
+	return strings.Join(groups, "|")
+}
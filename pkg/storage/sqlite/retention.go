@@ -0,0 +1,184 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sgaunet/ekspodlogs/internal/database"
+)
+
+// RetentionPolicy limits the logs kept for a loggroup, a zero value means no limit
+type RetentionPolicy struct {
+	MaxAge  time.Duration // The older logs are removed
+	MaxRows int64         // The oldest logs beyond this number are removed
+}
+
+// Retention is the retention of the logs of the database
+type Retention struct {
+	Default   RetentionPolicy            // Policy of the loggroups not listed in Loggroups
+	Loggroups map[string]RetentionPolicy // Policy by loggroup
+	MaxSize   int64                      // Size in bytes of the data of the database, the oldest logs of all the loggroups are removed beyond
+}
+
+// IsEmpty returns true if the retention removes no log
+func (r Retention) IsEmpty() bool {
+	return r.Default == RetentionPolicy{} && len(r.Loggroups) == 0 && r.MaxSize == 0
+}
+
+// ApplyRetention removes the logs exceeding the retention and returns the number of logs removed
+// The synced windows are trimmed so that the periods removed are reported as gaps.
+// The space freed is reused by the next syncs, Vacuum gives it back to the file system.
+func (s *Storage) ApplyRetention(ctx context.Context, r Retention) (int64, error) {
+	loggroups, err := s.queries.GetLoggroups(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get loggroups: %w", err)
+	}
+	var deleted int64
+	for _, loggroup := range loggroups {
+		policy, ok := r.Loggroups[loggroup]
+		if !ok {
+			policy = r.Default
+		}
+		if policy.MaxAge > 0 {
+			n, err := s.deleteLogsBefore(ctx, loggroup, s.Now().Add(-policy.MaxAge), 0)
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+		if policy.MaxRows > 0 {
+			n, err := s.deleteLogsBeyond(ctx, loggroup, policy.MaxRows)
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+	}
+	if r.MaxSize > 0 {
+		n, err := s.shrinkTo(ctx, r.MaxSize)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// deleteLogsBefore removes the logs of the loggroup up to the log (cutoffTime, cutoffID)
+// and trims the synced windows, all the loggroups are concerned if loggroup is empty
+func (s *Storage) deleteLogsBefore(ctx context.Context, loggroup string, cutoffTime time.Time, cutoffID int64) (int64, error) {
+	cutoffTime = cutoffTime.UTC() // the times are stored in UTC and compared as strings
+	var deleted int64
+	err := s.withRetry(ctx, func() error {
+		return s.inTx(ctx, func(q *database.Queries) error {
+			var err error
+			deleted, err = q.DeleteLogsBefore(ctx, database.DeleteLogsBeforeParams{
+				Loggroup:   loggroup,
+				CutoffTime: cutoffTime,
+				CutoffID:   cutoffID,
+			})
+			if err != nil {
+				return err
+			}
+			err = q.DeleteSyncWindowsBefore(ctx, database.DeleteSyncWindowsBeforeParams{
+				Loggroup:   loggroup,
+				CutoffTime: cutoffTime,
+			})
+			if err != nil {
+				return err
+			}
+			return q.TrimSyncWindowsBefore(ctx, database.TrimSyncWindowsBeforeParams{
+				Loggroup:   loggroup,
+				CutoffTime: cutoffTime,
+			})
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete logs: %w", err)
+	}
+	return deleted, nil
+}
+
+// deleteLogsBeyond removes the oldest logs of the loggroup to keep only maxRows logs,
+// all the loggroups are concerned if loggroup is empty
+func (s *Storage) deleteLogsBeyond(ctx context.Context, loggroup string, maxRows int64) (int64, error) {
+	last, err := s.queries.GetLogAtRank(ctx, database.GetLogAtRankParams{Loggroup: loggroup, Rank: maxRows})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the oldest log to keep: %w", err)
+	}
+	return s.deleteLogsBefore(ctx, loggroup, last.EventTime, last.ID)
+}
+
+// shrinkTo removes the oldest logs of all the loggroups until the data fits in maxSize bytes
+func (s *Storage) shrinkTo(ctx context.Context, maxSize int64) (int64, error) {
+	var deleted int64
+	// The size freed by the removal is an estimation, a few passes may be needed
+	for range 10 {
+		size, err := s.DataSize(ctx)
+		if err != nil {
+			return deleted, err
+		}
+		if size <= maxSize {
+			return deleted, nil
+		}
+		count, err := s.queries.CountLogsOfLoggroup(ctx, "")
+		if err != nil {
+			return deleted, fmt.Errorf("failed to count logs: %w", err)
+		}
+		if count == 0 {
+			return deleted, nil
+		}
+		keep := min(count*maxSize/size, count-1)
+		n, err := s.deleteLogsBeyond(ctx, "", keep)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// DataSize returns the size in bytes of the data of the database, without the free pages
+func (s *Storage) DataSize(ctx context.Context) (int64, error) {
+	var pageCount, freeCount, pageSize int64
+	for pragma, v := range map[string]*int64{"page_count": &pageCount, "freelist_count": &freeCount, "page_size": &pageSize} {
+		if err := s.db.QueryRowContext(ctx, "PRAGMA "+pragma).Scan(v); err != nil {
+			return 0, fmt.Errorf("failed to get %s: %w", pragma, err)
+		}
+	}
+	return (pageCount - freeCount) * pageSize, nil
+}
+
+// FileSize returns the size in bytes of the database file and of its write-ahead log
+func (s *Storage) FileSize() (int64, error) {
+	var size int64
+	for _, path := range []string{s.dbFile, s.dbFile + "-wal"} {
+		fi, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to get the size of the database: %w", err)
+		}
+		size += fi.Size()
+	}
+	return size, nil
+}
+
+// Vacuum rebuilds the database to give the free space back to the file system
+// and updates the statistics used by the query planner
+func (s *Storage) Vacuum(ctx context.Context) error {
+	for _, stmt := range []string{"VACUUM", "PRAGMA optimize", "PRAGMA wal_checkpoint(TRUNCATE)"} {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to run %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
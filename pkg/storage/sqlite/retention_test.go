@@ -0,0 +1,106 @@
+package sqlite_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
+)
+
+func TestApplyRetention(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+	now := time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)
+	s.SetNow(func() time.Time { return now })
+
+	// One log per day during 10 days in two loggroups
+	begin := now.AddDate(0, 0, -10)
+	for _, group := range []string{"api", "batch"} {
+		var records []sqlite.LogRecord
+		for i := range 10 {
+			records = append(records, sqlite.LogRecord{
+				EventID:   group + strconv.Itoa(i),
+				EventTime: begin.AddDate(0, 0, i),
+				Log:       strconv.Itoa(i),
+			})
+		}
+		if err := s.AddLogs(ctx, "dev", group, records); err != nil {
+			t.Fatalf("err returned by AddLogs(): %v", err.Error())
+		}
+		_ = s.AddSyncWindow(ctx, sqlite.Source{Profile: "dev", Loggroup: group}, sqlite.LogFilter{}, begin, now, len(records))
+	}
+
+	r := sqlite.Retention{
+		Default:   sqlite.RetentionPolicy{MaxAge: 72 * time.Hour},
+		Loggroups: map[string]sqlite.RetentionPolicy{"batch": {MaxRows: 5}},
+	}
+	deleted, err := s.ApplyRetention(ctx, r)
+	if err != nil {
+		t.Fatalf("err returned by ApplyRetention(): %v", err.Error())
+	}
+	if deleted != 7+5 {
+		t.Errorf("ApplyRetention() deleted %d logs, want 12", deleted)
+	}
+
+	b, e := carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(now)
+	for group, want := range map[string]string{"api": "7,8,9", "batch": "5,6,7,8,9"} {
+		logs, err := s.GetLogs(ctx, group, "dev", sqlite.LogFilter{}, b, e)
+		if err != nil {
+			t.Fatalf("err returned by GetLogs(): %v", err.Error())
+		}
+		var got []string
+		for _, l := range logs {
+			got = append(got, l.Log)
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("logs of %s after ApplyRetention() = %v, want %s", group, got, want)
+		}
+	}
+
+	// The period removed is not reported as synced anymore
+	covered, _ := s.GetCoverage(ctx, sqlite.Source{Profile: "dev", Loggroup: "api"}, sqlite.LogFilter{})
+	if len(covered) != 1 || !covered[0].Begin.Equal(now.Add(-72*time.Hour)) {
+		t.Errorf("GetCoverage() after ApplyRetention() = %v, want the last 3 days", covered)
+	}
+}
+
+func TestApplyRetentionMaxSize(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	var records []sqlite.LogRecord
+	for i := range 5000 {
+		records = append(records, sqlite.LogRecord{
+			EventID:   strconv.Itoa(i),
+			EventTime: begin.Add(time.Duration(i) * time.Second),
+			Log:       strings.Repeat("x", 200) + strconv.Itoa(i),
+		})
+	}
+	if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+	size, err := s.DataSize(ctx)
+	if err != nil {
+		t.Fatalf("err returned by DataSize(): %v", err.Error())
+	}
+
+	maxSize := size / 2
+	deleted, err := s.ApplyRetention(ctx, sqlite.Retention{MaxSize: maxSize})
+	if err != nil {
+		t.Fatalf("err returned by ApplyRetention(): %v", err.Error())
+	}
+	if size, _ = s.DataSize(ctx); size > maxSize {
+		t.Errorf("DataSize() after ApplyRetention() = %d, want at most %d", size, maxSize)
+	}
+	if deleted == 0 || deleted == 5000 {
+		t.Errorf("ApplyRetention() deleted %d logs, want the oldest ones", deleted)
+	}
+	if err := s.Vacuum(ctx); err != nil {
+		t.Errorf("err returned by Vacuum(): %v", err.Error())
+	}
+}
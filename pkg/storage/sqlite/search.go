@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dromara/carbon/v2"
+	"github.com/mattn/go-sqlite3"
+	"github.com/sgaunet/ekspodlogs/internal/database"
+)
+
+// Markers of the matching terms returned by the highlight() function of the full-text index
+const (
+	highlightStart = '\x01'
+	highlightEnd   = '\x02'
+)
+
+// ErrInvalidSearch is returned when the full-text query can not be parsed
+var ErrInvalidSearch = errors.New("invalid search query")
+
+// SearchResult is a log matching a full-text search
+type SearchResult struct {
+	database.Log
+	// Matches are the byte ranges [start, end) of the matching terms in Log
+	Matches [][]int
+}
+
+// SearchLogs returns the logs matching the full-text query, the most relevant first
+func (s *Storage) SearchLogs(ctx context.Context, logGroup string, profile string, filter LogFilter, search string, beginDate *carbon.Carbon, endDate *carbon.Carbon) ([]SearchResult, error) {
+	var res []SearchResult
+	err := s.IterateSearchResults(ctx, Target(profile, logGroup), filter, search, beginDate, endDate, IterateOptions{}, func(r SearchResult) error {
+		res = append(res, r)
+		return nil
+	})
+	return res, err
+}
+
+// IterateSearchResults calls fn for each log of the targets matching the full-text query, the most relevant first
+// The query uses the SQLite FTS5 syntax (e.g. "timeout AND payment", "pay*", "\"connection refused\""),
+// ErrInvalidSearch is returned if it can not be parsed. The logs are ranked by bm25.
+// The reverse order is not supported.
+func (s *Storage) IterateSearchResults(ctx context.Context, targets Targets, filter LogFilter, search string, beginDate *carbon.Carbon, endDate *carbon.Carbon, opts IterateOptions, fn func(SearchResult) error) error {
+	if opts.Reverse {
+		return errors.New("the results of a full-text search can not be returned in reverse order")
+	}
+	params := database.SearchLogsParams{
+		Search:             search,
+		Begindate:          beginDate.StdTime(),
+		Enddate:            endDate.StdTime(),
+		Loggroups:          listParam(targets.Loggroups),
+		Profiles:           listParam(targets.Profiles),
+		Regions:            listParam(targets.Regions),
+		PodName:            "%" + filter.PodName + "%",
+		NamespaceName:      filter.Namespace,
+		ContainerName:      filter.Container,
+		ExcludedContainers: filter.excludedContainersParam(),
+		ContainerImage:     "%" + filter.Image + "%",
+		Host:               filter.Node,
+		Stream:             filter.Stream,
+		Labels:             filter.labelsParam(),
+		NbLabels:           int64(len(filter.labels())),
+		Grep:               JoinRegexps(filter.Grep),
+		GrepV:              JoinRegexps(filter.GrepV),
+		Levels:             filter.levelsParam(),
+		Offset:             int64(opts.Offset),
+	}
+	remaining := opts.Limit
+	for {
+		params.Limit = pageSize
+		if opts.Limit > 0 {
+			params.Limit = int64(min(remaining, pageSize))
+		}
+		if params.Limit == 0 {
+			return nil
+		}
+		// The ranking has no cursor, the pages are read with an offset
+		rows, err := s.queries.SearchLogs(ctx, params)
+		if err != nil {
+			return searchError(search, err)
+		}
+		for _, r := range rows {
+			if err := fn(SearchResult{Log: r.Log, Matches: parseHighlight(r.Highlighted, r.Log.Log)}); err != nil {
+				return err
+			}
+		}
+		if int64(len(rows)) < params.Limit {
+			return nil
+		}
+		params.Offset += int64(len(rows))
+		remaining -= len(rows)
+	}
+}
+
+// parseHighlight returns the byte ranges of log surrounded by the markers of highlight()
+// No range is returned if the highlighted text does not match the log, e.g. if it contains the markers.
+func parseHighlight(highlighted string, log string) [][]int {
+	var matches [][]int
+	var sb strings.Builder
+	start := -1
+	for i := 0; i < len(highlighted); i++ {
+		switch highlighted[i] {
+		case highlightStart:
+			start = sb.Len()
+		case highlightEnd:
+			if start >= 0 {
+				matches = append(matches, []int{start, sb.Len()})
+				start = -1
+			}
+		default:
+			sb.WriteByte(highlighted[i])
+		}
+	}
+	if sb.String() != log {
+		return nil
+	}
+	return matches
+}
+
+// searchError returns ErrInvalidSearch for the errors of the parser of the full-text queries
+func searchError(search string, err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrError {
+		// e.g. "fts5: syntax error near "-"", "no such column: user" for "user:alice"
+		return fmt.Errorf("%w %q: %s (quote the terms containing special characters, e.g. '\"api-7d9f\"')", ErrInvalidSearch, search, strings.TrimPrefix(sqliteErr.Error(), "fts5: "))
+	}
+	return fmt.Errorf("failed to search logs: %w", err)
+}
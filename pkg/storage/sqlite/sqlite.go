@@ -4,17 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/url"
+	"math"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/amacneil/dbmate/v2/pkg/dbmate"
 	_ "github.com/amacneil/dbmate/v2/pkg/driver/sqlite"
 	"github.com/dromara/carbon/v2"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/coverage"
+	"github.com/sgaunet/ekspodlogs/pkg/level"
 )
 
 //go:embed db/migrations/*.sql
@@ -33,7 +36,7 @@ type Storage struct {
 func NewStorage(dbFile string) (*Storage, error) {
 	// Configure SQLite connection string for concurrent access
 	dbURL := fmt.Sprintf("file:%s?cache=shared&mode=rwc&_journal_mode=WAL&_synchronous=NORMAL&_timeout=5000", dbFile)
-	db, err := sql.Open("sqlite3", dbURL)
+	db, err := sql.Open(driverName, dbURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
 	}
@@ -71,10 +74,9 @@ func (s *Storage) Close() error {
 	return err
 }
 
+// Init creates the database if needed and applies the migrations
 func (s *Storage) Init() error {
-	u, _ := url.Parse(fmt.Sprintf("sqlite3://%s", s.dbFile))
-	db := dbmate.New(u)
-	db.FS = fs
+	db := s.migrator(os.Stdout)
 
 	fmt.Println("Migrations:")
 	migrations, err := db.FindMigrations()
@@ -84,10 +86,9 @@ func (s *Storage) Init() error {
 	for _, m := range migrations {
 		fmt.Println(m.Version, m.FilePath)
 	}
-	db.AutoDumpSchema = false
 	err = db.CreateAndMigrate()
 	if err != nil {
-		return fmt.Errorf("failed to create and migrate database: %w", err)
+		return fmt.Errorf("failed to create and migrate database: %w", withFTS5Hint(err))
 	}
 	return nil
 }
@@ -96,17 +97,25 @@ func (s *Storage) PurgeAll(ctx context.Context) error {
 	if err := s.queries.PurgeAll(ctx); err != nil {
 		return fmt.Errorf("failed to purge all logs: %w", err)
 	}
+	if err := s.queries.PurgeSyncWindows(ctx); err != nil {
+		return fmt.Errorf("failed to purge sync windows: %w", err)
+	}
+	if err := s.queries.PurgeSyncWatermarks(ctx); err != nil {
+		return fmt.Errorf("failed to purge sync watermarks: %w", err)
+	}
 	return nil
 }
 
-func (s *Storage) PurgeSpecificPeriod(ctx context.Context, profile string, loggroup string, podName string, beginDate *carbon.Carbon, endDate *carbon.Carbon) error {
-	podName = "%" + podName + "%"
+func (s *Storage) PurgeSpecificPeriod(ctx context.Context, profile string, loggroup string, filter LogFilter, beginDate *carbon.Carbon, endDate *carbon.Carbon) error {
 	err := s.queries.PurgeSpecificPeriod(ctx, database.PurgeSpecificPeriodParams{
-		Profile:   profile,
-		Loggroup:  loggroup,
-		PodName:   podName,
-		Begindate: beginDate.StdTime(),
-		Enddate:   endDate.StdTime(),
+		Profile:            profile,
+		Loggroup:           loggroup,
+		PodName:            "%" + filter.PodName + "%",
+		NamespaceName:      filter.Namespace,
+		ContainerName:      filter.Container,
+		ExcludedContainers: filter.excludedContainersParam(),
+		Begindate:          beginDate.StdTime(),
+		Enddate:            endDate.StdTime(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to purge specific period: %w", err)
@@ -114,26 +123,63 @@ func (s *Storage) PurgeSpecificPeriod(ctx context.Context, profile string, loggr
 	return nil
 }
 
-func (s *Storage) PurgeSpecificLogPodLogs(ctx context.Context, profile string, loggroup string, podName string) error {
-	podName = "%" + podName + "%"
+func (s *Storage) PurgeSpecificLogPodLogs(ctx context.Context, profile string, loggroup string, filter LogFilter) error {
 	err := s.queries.PurgeSpecificLogPodLogs(ctx, database.PurgeSpecificLogPodLogsParams{
+		Profile:            profile,
+		Loggroup:           loggroup,
+		PodName:            "%" + filter.PodName + "%",
+		NamespaceName:      filter.Namespace,
+		ContainerName:      filter.Container,
+		ExcludedContainers: filter.excludedContainersParam(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge specific log pod logs: %w", err)
+	}
+	// The synced windows of the removed pods are not covered anymore
+	err = s.queries.PurgeSpecificSyncWindows(ctx, database.PurgeSpecificSyncWindowsParams{
 		Profile:  profile,
 		Loggroup: loggroup,
-		PodName:  podName,
+		PodName:  filter.PodName,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to purge specific log pod logs: %w", err)
+		return fmt.Errorf("failed to purge specific sync windows: %w", err)
+	}
+	err = s.queries.PurgeSpecificSyncWatermarks(ctx, database.PurgeSpecificSyncWatermarksParams{
+		Profile:  profile,
+		Loggroup: loggroup,
+		PodName:  filter.PodName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge specific sync watermarks: %w", err)
 	}
 	return nil
 }
 
+// LogRecord is a log event to save in the database
+// EventID and LogStreamName identify the event in CloudWatch, an event already saved is ignored.
+type LogRecord struct {
+	EventID        string
+	LogStreamName  string
+	EventTime      time.Time
+	IngestionTime  time.Time // Time of ingestion in CloudWatch, zero if unknown
+	PodName        string
+	PodID          string
+	DockerID       string // ID of the container in the runtime
+	ContainerName  string
+	ContainerImage string
+	NamespaceName  string
+	Host           string // Node of the pod
+	Stream         string // stdout or stderr
+	Labels         map[string]string
+	Log            string
+	Level          level.Level
+	Region         string // AWS region of the log group
+	AccountID      string // AWS account of the log group
+}
+
 func (s *Storage) AddLog(ctx context.Context, profile string, loggroup string, eventTime time.Time, podName, containerName, nameSpace, log string) error {
-	const maxRetries = 3
-	const baseDelay = 10 * time.Millisecond
-	
-	var lastErr error
-	for i := 0; i < maxRetries; i++ {
-		if err := s.queries.InsertLog(ctx, database.InsertLogParams{
+	err := s.withRetry(ctx, func() error {
+		return s.queries.InsertLog(ctx, database.InsertLogParams{
 			EventTime:     eventTime,
 			Profile:       profile,
 			Loggroup:      loggroup,
@@ -141,27 +187,136 @@ func (s *Storage) AddLog(ctx context.Context, profile string, loggroup string, e
 			ContainerName: containerName,
 			NamespaceName: nameSpace,
 			Log:           log,
-		}); err != nil {
-			lastErr = err
-			// Check if it's a database lock error
-			errStr := fmt.Sprintf("%v", err)
-			if i < maxRetries-1 && (strings.Contains(errStr, "database is locked") || 
-				strings.Contains(errStr, "SQLITE_BUSY") ||
-				strings.Contains(errStr, "database lock")) {
-				// Wait with exponential backoff before retrying
-				delay := baseDelay * time.Duration(1<<uint(i))
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(delay):
-					continue
+			Labels:        "{}",
+			Level:         string(level.Detect(log)),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert log: %w", err)
+	}
+	return nil
+}
+
+// AddLogs saves the records in a single transaction, reusing the same prepared statement
+// Records already saved by a previous sync are ignored.
+func (s *Storage) AddLogs(ctx context.Context, profile string, loggroup string, records []LogRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	err := s.withRetry(ctx, func() error {
+		return s.inTx(ctx, func(q *database.Queries) error {
+			for _, r := range records {
+				labels, err := encodeLabels(r.Labels)
+				if err != nil {
+					return err
+				}
+				err = q.InsertLog(ctx, database.InsertLogParams{
+					EventTime:      r.EventTime,
+					Profile:        profile,
+					Loggroup:       loggroup,
+					PodName:        r.PodName,
+					ContainerName:  r.ContainerName,
+					NamespaceName:  r.NamespaceName,
+					Log:            r.Log,
+					EventID:        r.EventID,
+					LogStreamName:  r.LogStreamName,
+					IngestionTime:  sql.NullTime{Time: r.IngestionTime, Valid: !r.IngestionTime.IsZero()},
+					ContainerImage: r.ContainerImage,
+					PodID:          r.PodID,
+					DockerID:       r.DockerID,
+					Host:           r.Host,
+					Stream:         r.Stream,
+					Labels:         labels,
+					Level:          string(r.Level),
+					Region:         r.Region,
+					AccountID:      r.AccountID,
+				})
+				if err != nil {
+					return err
 				}
 			}
-			return fmt.Errorf("failed to insert log: %w", err)
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert %d logs: %w", len(records), err)
+	}
+	return nil
+}
+
+// encodeLabels returns the labels as a JSON object
+func encodeLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode labels: %w", err)
+	}
+	return string(b), nil
+}
+
+// DecodeLabels returns the labels stored as a JSON object
+func DecodeLabels(labels string) map[string]string {
+	var m map[string]string
+	_ = json.Unmarshal([]byte(labels), &m)
+	return m
+}
+
+// inTx runs fn in a transaction, committed if fn succeeds and rolled back otherwise
+// The queries given to fn prepare each statement once for the whole transaction.
+func (s *Storage) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	stmts := newStmtCache(tx)
+	defer func() { _ = stmts.Close() }()
+
+	if err := fn(database.New(stmts)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
 		}
-		return nil
+		return err
 	}
-	return fmt.Errorf("failed to insert log after %d retries: %w", maxRetries, lastErr)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// withRetry calls fn again with an exponential backoff while the database is locked
+func (s *Storage) withRetry(ctx context.Context, fn func() error) error {
+	const maxRetries = 3
+	const baseDelay = 10 * time.Millisecond
+
+	var lastErr error
+	for i := 0; i < maxRetries; i++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if i == maxRetries-1 || !isLockError(err) {
+			return err
+		}
+		// Wait with exponential backoff before retrying
+		delay := baseDelay * time.Duration(1<<uint(i))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
+}
+
+// isLockError returns true if the error is caused by a database lock
+func isLockError(err error) bool {
+	errStr := err.Error()
+	return strings.Contains(errStr, "database is locked") ||
+		strings.Contains(errStr, "SQLITE_BUSY") ||
+		strings.Contains(errStr, "database lock")
 }
 
 func (s *Storage) GetLogsOfPod(ctx context.Context, profile string, logGroup string, podName string, beginDate, endDate time.Time) ([]database.Log, error) {
@@ -178,17 +333,195 @@ func (s *Storage) GetLogsOfPod(ctx context.Context, profile string, logGroup str
 	return logs, nil
 }
 
-func (s *Storage) GetLogs(ctx context.Context, logGroup string, profile string, podName string, beginDate *carbon.Carbon, endDate *carbon.Carbon) ([]database.Log, error) {
-	podName = "%" + podName + "%"
-	logs, err := s.queries.GetLogs(ctx, database.GetLogsParams{
-		Begindate: beginDate.StdTime(),
-		Enddate:   endDate.StdTime(),
-		Loggroup:  logGroup,
-		Profile:   profile,
-		PodName:   podName,
+// GetLogs returns the logs selected by the filter in chronological order
+func (s *Storage) GetLogs(ctx context.Context, logGroup string, profile string, filter LogFilter, beginDate *carbon.Carbon, endDate *carbon.Carbon) ([]database.Log, error) {
+	var logs []database.Log
+	err := s.IterateLogs(ctx, Target(profile, logGroup), filter, beginDate, endDate, IterateOptions{}, func(l database.Log) error {
+		logs = append(logs, l)
+		return nil
 	})
+	return logs, err
+}
+
+// pageSize is the number of logs read at once by IterateLogs
+const pageSize = 1000
+
+// IterateOptions selects the range of logs returned by IterateLogs
+type IterateOptions struct {
+	Limit   int  // Maximum number of logs, 0 for no limit
+	Offset  int  // Number of logs skipped
+	Reverse bool  // The most recent logs first
+	AfterID int64 // Only the logs saved after the log of this identifier, see LastLogID
+}
+
+// LastLogID returns the identifier of the last log saved, 0 if the database is empty
+// The identifiers are increasing, the logs saved later have a greater identifier.
+func (s *Storage) LastLogID(ctx context.Context) (int64, error) {
+	id, err := s.queries.GetLastLogID(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get logs: %w", err)
+		return 0, fmt.Errorf("failed to get the last log id: %w", err)
 	}
-	return logs, nil
+	return id, nil
+}
+
+// IterateLogs calls fn for each log of the targets selected by the filter, in chronological order or the most recent first
+// The logs are read by pages, the memory used does not depend on the number of logs.
+// The iteration stops at the first error returned by fn.
+func (s *Storage) IterateLogs(ctx context.Context, targets Targets, filter LogFilter, beginDate *carbon.Carbon, endDate *carbon.Carbon, opts IterateOptions, fn func(database.Log) error) error {
+	params := database.GetLogsPageParams{
+		Begindate:          beginDate.StdTime(),
+		Enddate:            endDate.StdTime(),
+		CursorTime:         beginDate.StdTime(),
+		CursorID:           0,
+		Loggroups:          listParam(targets.Loggroups),
+		Profiles:           listParam(targets.Profiles),
+		Regions:            listParam(targets.Regions),
+		PodName:            "%" + filter.PodName + "%",
+		NamespaceName:      filter.Namespace,
+		ContainerName:      filter.Container,
+		ExcludedContainers: filter.excludedContainersParam(),
+		ContainerImage:     "%" + filter.Image + "%",
+		Host:               filter.Node,
+		Stream:             filter.Stream,
+		Labels:             filter.labelsParam(),
+		NbLabels:           int64(len(filter.labels())),
+		Grep:               JoinRegexps(filter.Grep),
+		GrepV:              JoinRegexps(filter.GrepV),
+		Levels:             filter.levelsParam(),
+		AfterID:            opts.AfterID,
+		Offset:             int64(opts.Offset),
+	}
+	if opts.Reverse {
+		params.CursorTime = endDate.StdTime()
+		params.CursorID = math.MaxInt64
+	}
+	remaining := opts.Limit
+	for {
+		params.Limit = pageSize
+		if opts.Limit > 0 {
+			params.Limit = int64(min(remaining, pageSize))
+		}
+		if params.Limit == 0 {
+			return nil
+		}
+		var page []database.Log
+		var err error
+		if opts.Reverse {
+			page, err = s.queries.GetLogsPageReverse(ctx, database.GetLogsPageReverseParams(params))
+		} else {
+			page, err = s.queries.GetLogsPage(ctx, params)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get logs: %w", err)
+		}
+		for _, l := range page {
+			if err := fn(l); err != nil {
+				return err
+			}
+		}
+		if int64(len(page)) < params.Limit {
+			return nil
+		}
+		// The next page starts after the last log, the offset only applies to the first page
+		last := page[len(page)-1]
+		params.CursorTime, params.CursorID = last.EventTime, last.ID
+		params.Offset = 0
+		remaining -= len(page)
+	}
+}
+
+// GetSyncWatermark returns the time of the last event synced for the source and filter
+// The boolean is false if no incremental sync has been recorded yet
+func (s *Storage) GetSyncWatermark(ctx context.Context, src Source, filter LogFilter) (time.Time, bool, error) {
+	w, err := s.queries.GetSyncWatermark(ctx, database.GetSyncWatermarkParams{
+		Profile:            src.Profile,
+		Region:             src.Region,
+		AccountID:          src.AccountID,
+		Loggroup:           src.Loggroup,
+		PodFilter:          filter.PodName,
+		NamespaceFilter:    filter.Namespace,
+		ContainerFilter:    filter.Container,
+		ExcludedContainers: filter.excludedContainersKey(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get sync watermark: %w", err)
+	}
+	return w.LastEventTime, true, nil
+}
+
+// SetSyncWatermark records the time of the last event synced for the source and filter
+// An earlier time than the recorded one does not move the watermark back.
+func (s *Storage) SetSyncWatermark(ctx context.Context, src Source, filter LogFilter, lastEventTime time.Time) error {
+	err := s.queries.UpsertSyncWatermark(ctx, database.UpsertSyncWatermarkParams{
+		Profile:            src.Profile,
+		Region:             src.Region,
+		AccountID:          src.AccountID,
+		Loggroup:           src.Loggroup,
+		PodFilter:          filter.PodName,
+		NamespaceFilter:    filter.Namespace,
+		ContainerFilter:    filter.Container,
+		ExcludedContainers: filter.excludedContainersKey(),
+		LastEventTime:      lastEventTime.UTC(),
+		UpdatedAt:          s.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set sync watermark: %w", err)
+	}
+	return nil
+}
+
+// AddSyncWindow records a period successfully synchronised for the source and filter
+func (s *Storage) AddSyncWindow(ctx context.Context, src Source, filter LogFilter, beginDate, endDate time.Time, eventCount int) error {
+	err := s.queries.InsertSyncWindow(ctx, database.InsertSyncWindowParams{
+		Profile:            src.Profile,
+		Region:             src.Region,
+		AccountID:          src.AccountID,
+		Loggroup:           src.Loggroup,
+		PodFilter:          filter.PodName,
+		NamespaceFilter:    filter.Namespace,
+		ContainerFilter:    filter.Container,
+		ExcludedContainers: filter.excludedContainersKey(),
+		BeginTime:          beginDate.UTC(),
+		EndTime:            endDate.UTC(),
+		EventCount:         int64(eventCount),
+		FinishedAt:         s.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add sync window: %w", err)
+	}
+	return nil
+}
+
+// GetSyncWindows returns all the periods synchronised, ordered by source, filter and begin date
+func (s *Storage) GetSyncWindows(ctx context.Context) ([]database.SyncWindow, error) {
+	windows, err := s.queries.GetSyncWindows(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync windows: %w", err)
+	}
+	return windows, nil
+}
+
+// GetCoverage returns the merged periods during which the logs selected by the filter are synchronised
+// A window synced with a filter covers every request selecting a subset of its logs.
+// An empty region or account of the source selects the windows of all the regions or accounts.
+func (s *Storage) GetCoverage(ctx context.Context, src Source, filter LogFilter) ([]coverage.Interval, error) {
+	windows, err := s.queries.GetSyncWindowsOfLogGroup(ctx, database.GetSyncWindowsOfLogGroupParams{
+		Profile:   src.Profile,
+		Region:    src.Region,
+		AccountID: src.AccountID,
+		Loggroup:  src.Loggroup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync windows: %w", err)
+	}
+	var intervals []coverage.Interval
+	for _, w := range windows {
+		if FilterOfSyncWindow(w).Covers(filter) {
+			intervals = append(intervals, coverage.Interval{Begin: w.BeginTime, End: w.EndTime})
+		}
+	}
+	return coverage.Merge(intervals), nil
 }
@@ -1,11 +1,20 @@
 package sqlite_test
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	_ "github.com/amacneil/dbmate/v2/pkg/driver/sqlite"
+	"github.com/dromara/carbon/v2"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/sgaunet/ekspodlogs/internal/database"
+	"github.com/sgaunet/ekspodlogs/pkg/level"
 	"github.com/sgaunet/ekspodlogs/pkg/storage/sqlite"
 )
 
@@ -40,3 +49,486 @@ func TestInit(t *testing.T) {
 	// delete db file
 	os.Remove("/tmp/db.sqlite3")
 }
+
+// newTestStorage returns an initialised storage in a temporary directory, closed at the end of the test
+func newTestStorage(t *testing.T) *sqlite.Storage {
+	t.Helper()
+	s, err := sqlite.NewStorage(filepath.Join(t.TempDir(), "db.sqlite3"))
+	if err != nil {
+		t.Fatalf("err returned by NewStorage(): %v", err.Error())
+	}
+	t.Cleanup(func() { s.Close() })
+	if err := s.Init(); err != nil {
+		t.Fatalf("err returned by Init(): %v", err.Error())
+	}
+	return s
+}
+
+func TestSyncWatermark(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+	src := sqlite.Source{Profile: "dev", Region: "eu-west-1", AccountID: "123456789012", Loggroup: "group"}
+
+	_, found, err := s.GetSyncWatermark(ctx, src, sqlite.LogFilter{PodName: "api"})
+	if err != nil {
+		t.Fatalf("err returned by GetSyncWatermark(): %v", err.Error())
+	}
+	if found {
+		t.Errorf("GetSyncWatermark() found a watermark in an empty database")
+	}
+
+	first := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	// The period fetched again before the watermark does not move it back
+	for _, w := range []time.Time{first, second, second.Add(-time.Minute)} {
+		if err := s.SetSyncWatermark(ctx, src, sqlite.LogFilter{PodName: "api"}, w); err != nil {
+			t.Fatalf("err returned by SetSyncWatermark(): %v", err.Error())
+		}
+	}
+
+	w, found, err := s.GetSyncWatermark(ctx, src, sqlite.LogFilter{PodName: "api"})
+	if err != nil {
+		t.Fatalf("err returned by GetSyncWatermark(): %v", err.Error())
+	}
+	if !found || !w.Equal(second) {
+		t.Errorf("GetSyncWatermark() = %v, %v, want %v, true", w, found, second)
+	}
+
+	_, found, _ = s.GetSyncWatermark(ctx, src, sqlite.LogFilter{PodName: "other"})
+	if found {
+		t.Errorf("GetSyncWatermark() returned the watermark of another pod filter")
+	}
+
+	// The same log group in another account or region has its own watermark
+	other := src
+	other.AccountID = "210987654321"
+	_, found, _ = s.GetSyncWatermark(ctx, other, sqlite.LogFilter{PodName: "api"})
+	if found {
+		t.Errorf("GetSyncWatermark() returned the watermark of another account")
+	}
+	other = src
+	other.Region = "us-east-1"
+	_, found, _ = s.GetSyncWatermark(ctx, other, sqlite.LogFilter{PodName: "api"})
+	if found {
+		t.Errorf("GetSyncWatermark() returned the watermark of another region")
+	}
+
+	// The watermark recorded without region and account is used until the first sync of the source
+	legacy := sqlite.Source{Profile: "dev", Loggroup: "group"}
+	if err := s.SetSyncWatermark(ctx, legacy, sqlite.LogFilter{PodName: "api"}, first); err != nil {
+		t.Fatalf("err returned by SetSyncWatermark(): %v", err.Error())
+	}
+	if w, found, _ := s.GetSyncWatermark(ctx, other, sqlite.LogFilter{PodName: "api"}); !found || !w.Equal(first) {
+		t.Errorf("GetSyncWatermark() = %v, %v, want the watermark without region %v, true", w, found, first)
+	}
+	if w, _, _ := s.GetSyncWatermark(ctx, src, sqlite.LogFilter{PodName: "api"}); !w.Equal(second) {
+		t.Errorf("GetSyncWatermark() = %v, want the watermark of the source %v", w, second)
+	}
+}
+
+func TestGetCoverage(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	dev := sqlite.Source{Profile: "dev", Region: "eu-west-1", AccountID: "123456789012", Loggroup: "group"}
+	_ = s.AddSyncWindow(ctx, dev, sqlite.LogFilter{}, begin, begin.Add(time.Hour), 10)
+	_ = s.AddSyncWindow(ctx, dev, sqlite.LogFilter{PodName: "api"}, begin.Add(time.Hour), begin.Add(2*time.Hour), 5)
+	_ = s.AddSyncWindow(ctx, sqlite.Source{Profile: "prod", Loggroup: "group"}, sqlite.LogFilter{}, begin.Add(2*time.Hour), begin.Add(3*time.Hour), 5)
+	other := dev
+	other.AccountID = "210987654321"
+	_ = s.AddSyncWindow(ctx, other, sqlite.LogFilter{}, begin.Add(3*time.Hour), begin.Add(4*time.Hour), 5)
+
+	covered, err := s.GetCoverage(ctx, dev, sqlite.LogFilter{PodName: "api-7d9f"})
+	if err != nil {
+		t.Fatalf("err returned by GetCoverage(): %v", err.Error())
+	}
+	if len(covered) != 1 || !covered[0].Begin.Equal(begin) || !covered[0].End.Equal(begin.Add(2*time.Hour)) {
+		t.Errorf("GetCoverage() = %v, want one interval of two hours", covered)
+	}
+
+	covered, _ = s.GetCoverage(ctx, dev, sqlite.LogFilter{PodName: "worker"})
+	if len(covered) != 1 || !covered[0].End.Equal(begin.Add(time.Hour)) {
+		t.Errorf("GetCoverage() = %v, want only the window synced without pod filter", covered)
+	}
+
+	// Without account, the windows of all the accounts are selected
+	covered, _ = s.GetCoverage(ctx, sqlite.Source{Profile: "dev", Region: "eu-west-1", Loggroup: "group"}, sqlite.LogFilter{})
+	if len(covered) != 2 || !covered[1].Begin.Equal(begin.Add(3*time.Hour)) {
+		t.Errorf("GetCoverage() = %v, want the windows of the two accounts", covered)
+	}
+	covered, _ = s.GetCoverage(ctx, sqlite.Source{Profile: "dev", Region: "us-east-1", Loggroup: "group"}, sqlite.LogFilter{})
+	if len(covered) != 0 {
+		t.Errorf("GetCoverage() = %v, want no window in another region", covered)
+	}
+}
+
+func TestAddLogs(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := make([]sqlite.LogRecord, 1000)
+	for i := range records {
+		records[i] = sqlite.LogRecord{
+			EventTime:     begin.Add(time.Duration(i) * time.Second),
+			PodName:       "api-7d9f",
+			ContainerName: "api",
+			NamespaceName: "default",
+			DockerID:      "0123456789ab",
+			Log:           "line",
+		}
+	}
+	if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+
+	logs, err := s.GetLogs(ctx, "group", "dev", sqlite.LogFilter{PodName: "api"}, carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("err returned by GetLogs(): %v", err.Error())
+	}
+	if len(logs) != len(records) {
+		t.Errorf("GetLogs() returned %d logs, want %d", len(logs), len(records))
+	}
+	if len(logs) > 0 && logs[0].DockerID != "0123456789ab" {
+		t.Errorf("GetLogs() returned the docker id %q, want 0123456789ab", logs[0].DockerID)
+	}
+}
+
+func TestAddLogsIgnoresDuplicates(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []sqlite.LogRecord{
+		{EventID: "1", LogStreamName: "stream", EventTime: begin, PodName: "api", Log: "first"},
+		{EventID: "2", LogStreamName: "stream", EventTime: begin, PodName: "api", Log: "second"},
+	}
+	// A retried sync saves the same events again
+	for range 2 {
+		if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+			t.Fatalf("err returned by AddLogs(): %v", err.Error())
+		}
+	}
+	// The same event id in another log group is another event
+	if err := s.AddLogs(ctx, "dev", "other", records[:1]); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+	// The same event id in the log group of another account is another event
+	otherAccount := records[0]
+	otherAccount.AccountID = "210987654321"
+	if err := s.AddLogs(ctx, "dev", "group", []sqlite.LogRecord{otherAccount}); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+
+	logs, err := s.GetLogs(ctx, "group", "dev", sqlite.LogFilter{}, carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("err returned by GetLogs(): %v", err.Error())
+	}
+	if len(logs) != len(records)+1 {
+		t.Errorf("GetLogs() returned %d logs, want %d", len(logs), len(records)+1)
+	}
+}
+
+func TestAddLogsKeepsMilliseconds(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []sqlite.LogRecord{
+		{EventID: "3", EventTime: begin.Add(1 * time.Second), Log: "third"},
+		{EventID: "2", EventTime: begin.Add(500 * time.Millisecond), Log: "second"},
+		{EventID: "1", EventTime: begin.Add(25 * time.Millisecond), IngestionTime: begin.Add(2 * time.Second), Log: "first"},
+	}
+	if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+
+	logs, err := s.GetLogs(ctx, "group", "dev", sqlite.LogFilter{}, carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("err returned by GetLogs(): %v", err.Error())
+	}
+	if len(logs) != 3 {
+		t.Fatalf("GetLogs() returned %d logs, want 3", len(logs))
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		if logs[i].Log != want {
+			t.Errorf("GetLogs()[%d] = %q, want %q", i, logs[i].Log, want)
+		}
+	}
+	if !logs[0].EventTime.Equal(records[2].EventTime) {
+		t.Errorf("EventTime = %v, want %v", logs[0].EventTime, records[2].EventTime)
+	}
+	if !logs[0].IngestionTime.Valid || !logs[0].IngestionTime.Time.Equal(records[2].IngestionTime) {
+		t.Errorf("IngestionTime = %v, want %v", logs[0].IngestionTime, records[2].IngestionTime)
+	}
+}
+
+func TestGetLogsWithFilter(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []sqlite.LogRecord{
+		{EventID: "1", EventTime: begin, PodName: "api-1", NamespaceName: "prod", ContainerName: "api"},
+		{EventID: "2", EventTime: begin, PodName: "api-1", NamespaceName: "prod", ContainerName: "istio-proxy"},
+		{EventID: "3", EventTime: begin, PodName: "api-2", NamespaceName: "dev", ContainerName: "api"},
+		{EventID: "4", EventTime: begin, PodName: "worker-1", NamespaceName: "prod", ContainerName: "worker"},
+		{EventID: "5", EventTime: begin, PodName: "front-1", NamespaceName: "prod", ContainerName: "front",
+			ContainerImage: "front:1.2.0", Host: "node-a", Stream: "stderr", Labels: map[string]string{"app": "front", "tier": "web"}},
+	}
+	if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+
+	tests := []struct {
+		name   string
+		filter sqlite.LogFilter
+		want   int
+	}{
+		{"no filter", sqlite.LogFilter{}, 5},
+		{"namespace", sqlite.LogFilter{Namespace: "prod"}, 4},
+		{"container", sqlite.LogFilter{Container: "api"}, 2},
+		{"excluded container", sqlite.LogFilter{ExcludedContainers: []string{"istio-proxy", "worker"}}, 3},
+		{"pod and namespace", sqlite.LogFilter{PodName: "api", Namespace: "prod", ExcludedContainers: []string{"istio-proxy"}}, 1},
+		{"image", sqlite.LogFilter{Image: "1.2"}, 1},
+		{"node", sqlite.LogFilter{Node: "node-a"}, 1},
+		{"stderr", sqlite.LogFilter{Stream: "stderr"}, 1},
+		{"label", sqlite.LogFilter{Labels: []string{"app=front"}}, 1},
+		{"labels", sqlite.LogFilter{Labels: []string{"app=front", "tier=web"}}, 1},
+		{"unknown label", sqlite.LogFilter{Labels: []string{"app=front", "tier=db"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := s.GetLogs(ctx, "group", "dev", tt.filter, carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour)))
+			if err != nil {
+				t.Fatalf("err returned by GetLogs(): %v", err.Error())
+			}
+			if len(logs) != tt.want {
+				t.Errorf("GetLogs() returned %d logs, want %d", len(logs), tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchLogs(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []sqlite.LogRecord{
+		{EventID: "1", EventTime: begin, PodName: "api-1", Log: "payment accepted"},
+		{EventID: "2", EventTime: begin.Add(time.Second), PodName: "api-1", Log: "timeout while calling payment"},
+		{EventID: "3", EventTime: begin.Add(2 * time.Second), PodName: "api-2", Log: "payment timeout, payment cancelled"},
+		{EventID: "4", EventTime: begin.Add(3 * time.Second), PodName: "worker-1", Log: "timeout"},
+	}
+	if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+	b, e := carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour))
+
+	res, err := s.SearchLogs(ctx, "group", "dev", sqlite.LogFilter{}, "timeout AND payment", b, e)
+	if err != nil {
+		t.Fatalf("err returned by SearchLogs(): %v", err.Error())
+	}
+	if len(res) != 2 {
+		t.Fatalf("SearchLogs() returned %d logs, want 2", len(res))
+	}
+	// the most relevant log (bm25) comes first
+	if res[0].EventID != "3" {
+		t.Errorf("SearchLogs() first result is %q, want 3", res[0].EventID)
+	}
+	want := [][]int{{0, 7}, {8, 15}, {17, 24}}
+	if !reflect.DeepEqual(res[0].Matches, want) {
+		t.Errorf("SearchLogs() matches = %v, want %v", res[0].Matches, want)
+	}
+
+	res, err = s.SearchLogs(ctx, "group", "dev", sqlite.LogFilter{PodName: "api-1"}, "timeout", b, e)
+	if err != nil {
+		t.Fatalf("err returned by SearchLogs(): %v", err.Error())
+	}
+	if len(res) != 1 || res[0].EventID != "2" {
+		t.Errorf("SearchLogs() with a pod filter returned %v", res)
+	}
+
+	for _, search := range []string{"api-1", "payment AND", "user:alice"} {
+		if _, err := s.SearchLogs(ctx, "group", "dev", sqlite.LogFilter{}, search, b, e); !errors.Is(err, sqlite.ErrInvalidSearch) {
+			t.Errorf("SearchLogs(%q) returned %v, want ErrInvalidSearch", search, err)
+		}
+	}
+
+	// the index follows the deletions of the logs table
+	if err := s.PurgeAll(ctx); err != nil {
+		t.Fatalf("err returned by PurgeAll(): %v", err.Error())
+	}
+	res, err = s.SearchLogs(ctx, "group", "dev", sqlite.LogFilter{}, "timeout", b, e)
+	if err != nil {
+		t.Fatalf("err returned by SearchLogs(): %v", err.Error())
+	}
+	if len(res) != 0 {
+		t.Errorf("SearchLogs() returned %d logs after purge, want 0", len(res))
+	}
+}
+
+func TestGetLogsWithContentFilter(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	records := []sqlite.LogRecord{
+		{EventID: "1", EventTime: begin, PodName: "api-1", Log: "GET /health 200", Level: level.Debug},
+		{EventID: "2", EventTime: begin, PodName: "api-1", Log: "GET /orders 500", Level: level.Error},
+		{EventID: "3", EventTime: begin, PodName: "api-1", Log: "POST /orders 201", Level: level.Info},
+		{EventID: "4", EventTime: begin, PodName: "api-1", Log: "POST /orders 503", Level: level.Fatal},
+	}
+	if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+
+	tests := []struct {
+		name   string
+		filter sqlite.LogFilter
+		want   int
+	}{
+		{"grep", sqlite.LogFilter{Grep: []string{` 5\d\d$`}}, 2},
+		{"grep any", sqlite.LogFilter{Grep: []string{`^GET`, `201$`}}, 3},
+		{"grep-v", sqlite.LogFilter{GrepV: []string{`/health`}}, 3},
+		{"grep and grep-v", sqlite.LogFilter{Grep: []string{`/orders`}, GrepV: []string{`^GET`, `201$`}}, 1},
+		{"level", sqlite.LogFilter{Level: level.Warn}, 2},
+		{"level and grep", sqlite.LogFilter{Level: level.Info, Grep: []string{`^POST`}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := s.GetLogs(ctx, "group", "dev", tt.filter, carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour)))
+			if err != nil {
+				t.Fatalf("err returned by GetLogs(): %v", err.Error())
+			}
+			if len(logs) != tt.want {
+				t.Errorf("GetLogs() returned %d logs, want %d", len(logs), tt.want)
+			}
+		})
+	}
+}
+
+func TestIterateLogs(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	// More logs than a page, several logs share the same time
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	var records []sqlite.LogRecord
+	for i := range 2500 {
+		records = append(records, sqlite.LogRecord{
+			EventID:   strconv.Itoa(i),
+			EventTime: begin.Add(time.Duration(i/3) * time.Millisecond),
+			PodName:   "api-1",
+			Log:       strconv.Itoa(i),
+		})
+	}
+	if err := s.AddLogs(ctx, "dev", "group", records); err != nil {
+		t.Fatalf("err returned by AddLogs(): %v", err.Error())
+	}
+	b, e := carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour))
+	if id, err := s.LastLogID(ctx); err != nil || id != 2500 {
+		t.Fatalf("LastLogID() = %d, %v, want 2500", id, err)
+	}
+
+	tests := []struct {
+		name  string
+		opts  sqlite.IterateOptions
+		first string
+		last  string
+		count int
+	}{
+		{"all", sqlite.IterateOptions{}, "0", "2499", 2500},
+		{"limit and offset", sqlite.IterateOptions{Limit: 1500, Offset: 10}, "10", "1509", 1500},
+		{"reverse", sqlite.IterateOptions{Reverse: true}, "2499", "0", 2500},
+		{"reverse with limit", sqlite.IterateOptions{Reverse: true, Limit: 3, Offset: 1}, "2498", "2496", 3},
+		{"after id", sqlite.IterateOptions{AfterID: 2000}, "2000", "2499", 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs []string
+			err := s.IterateLogs(ctx, sqlite.Target("dev", "group"), sqlite.LogFilter{}, b, e, tt.opts, func(l database.Log) error {
+				logs = append(logs, l.Log)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("err returned by IterateLogs(): %v", err.Error())
+			}
+			if len(logs) != tt.count {
+				t.Fatalf("IterateLogs() returned %d logs, want %d", len(logs), tt.count)
+			}
+			if logs[0] != tt.first || logs[len(logs)-1] != tt.last {
+				t.Errorf("IterateLogs() returned %s..%s, want %s..%s", logs[0], logs[len(logs)-1], tt.first, tt.last)
+			}
+			for i := 1; i < len(logs); i++ {
+				prev, _ := strconv.Atoi(logs[i-1])
+				cur, _ := strconv.Atoi(logs[i])
+				if (cur > prev) == tt.opts.Reverse {
+					t.Fatalf("IterateLogs() returned %d after %d", cur, prev)
+				}
+			}
+		})
+	}
+}
+
+func TestIterateLogsTargets(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	// The logs of the targets are interleaved in time
+	begin := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	adds := []struct {
+		profile, region, group string
+		seconds                []int
+	}{
+		{"dev", "eu-west-1", "group", []int{0, 3}},
+		{"prod", "eu-west-1", "group", []int{1, 4}},
+		{"prod", "us-east-1", "other", []int{2, 5}},
+		{"prod", "", "group", []int{6}}, // synced by a previous version, without region
+	}
+	for _, a := range adds {
+		var records []sqlite.LogRecord
+		for _, sec := range a.seconds {
+			records = append(records, sqlite.LogRecord{
+				EventID:   a.profile + a.region + strconv.Itoa(sec),
+				EventTime: begin.Add(time.Duration(sec) * time.Second),
+				Log:       strconv.Itoa(sec),
+				Region:    a.region,
+				AccountID: "123456789012",
+			})
+		}
+		if err := s.AddLogs(ctx, a.profile, a.group, records); err != nil {
+			t.Fatalf("err returned by AddLogs(): %v", err.Error())
+		}
+	}
+	b, e := carbon.CreateFromStdTime(begin), carbon.CreateFromStdTime(begin.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		targets sqlite.Targets
+		want    []string
+	}{
+		{"all", sqlite.Targets{}, []string{"0", "1", "2", "3", "4", "5", "6"}},
+		{"profiles", sqlite.Targets{Profiles: []string{"dev", "prod"}, Loggroups: []string{"group"}}, []string{"0", "1", "3", "4", "6"}},
+		{"region", sqlite.Targets{Profiles: []string{"prod"}, Regions: []string{"us-east-1"}}, []string{"2", "5"}},
+		{"region and previous logs", sqlite.Targets{Regions: []string{"eu-west-1", ""}}, []string{"0", "1", "3", "4", "6"}},
+		{"groups", sqlite.Targets{Loggroups: []string{"group", "other"}, Profiles: []string{"prod"}}, []string{"1", "2", "4", "5", "6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs []string
+			err := s.IterateLogs(ctx, tt.targets, sqlite.LogFilter{}, b, e, sqlite.IterateOptions{}, func(l database.Log) error {
+				logs = append(logs, l.Log)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("err returned by IterateLogs(): %v", err.Error())
+			}
+			if !reflect.DeepEqual(logs, tt.want) {
+				t.Errorf("IterateLogs() returned %v, want %v", logs, tt.want)
+			}
+		})
+	}
+}
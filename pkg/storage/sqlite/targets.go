@@ -0,0 +1,33 @@
+package sqlite
+
+import "strings"
+
+// Targets selects the logs of several profiles, regions and loggroups, all their combinations are selected
+// An empty list selects all the values, "" is the default profile.
+type Targets struct {
+	Profiles  []string
+	Regions   []string
+	Loggroups []string
+}
+
+// Target selects the logs of a loggroup of a profile, in all the regions
+func Target(profile, loggroup string) Targets {
+	return Targets{Profiles: []string{profile}, Loggroups: []string{loggroup}}
+}
+
+// Source is a loggroup of an AWS account and region, synced with a profile
+// The synced periods are recorded by source, the same loggroup may exist in several accounts and regions.
+type Source struct {
+	Profile   string
+	Region    string // Region of the loggroup, empty if unknown
+	AccountID string // Account of the loggroup, empty if unknown
+	Loggroup  string
+}
+
+// listParam returns the values as expected by the queries: ",a,b,", empty to select all the values
+func listParam(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return "," + strings.Join(values, ",") + ","
+}
@@ -2,6 +2,8 @@ package views
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/pterm/pterm"
@@ -13,6 +15,10 @@ type TerminalView struct {
 	nbStreamsScanned       atomic.Int64
 	spinnerRetrieveStreams *pterm.SpinnerPrinter
 	spinnerScanStreams     *pterm.SpinnerPrinter
+	nbEventsSaved          atomic.Int64
+	shardsMu               sync.Mutex
+	shardPages             []int
+	shardDone              []bool
 }
 
 func NewTerminalView() *TerminalView {
@@ -65,3 +71,55 @@ func (v *TerminalView) StopSpinnerRetrieveLogStreams() {
 func (v *TerminalView) StopSpinnerScanLogStreams() {
 	v.spinnerScanStreams.Success("Log streams scanned")
 }
+
+// InitShards resets the progress of the n time shards of a sync
+func (v *TerminalView) InitShards(n int) {
+	v.shardsMu.Lock()
+	defer v.shardsMu.Unlock()
+	v.shardPages = make([]int, n)
+	v.shardDone = make([]bool, n)
+	v.nbEventsSaved.Store(0)
+}
+
+// IncShardPages increments the number of pages retrieved by a shard
+func (v *TerminalView) IncShardPages(shard int) {
+	v.shardsMu.Lock()
+	defer v.shardsMu.Unlock()
+	v.shardPages[shard]++
+	v.updateSpinnerShards()
+}
+
+// SetShardDone marks a shard as completed
+func (v *TerminalView) SetShardDone(shard int) {
+	v.shardsMu.Lock()
+	defer v.shardsMu.Unlock()
+	v.shardDone[shard] = true
+	v.updateSpinnerShards()
+}
+
+// AddEventsSaved adds n to the number of events saved in the database
+func (v *TerminalView) AddEventsSaved(n int) {
+	v.nbEventsSaved.Add(int64(n))
+	v.shardsMu.Lock()
+	defer v.shardsMu.Unlock()
+	v.updateSpinnerShards()
+}
+
+// updateSpinnerShards prints the progress of each shard, shardsMu must be held
+func (v *TerminalView) updateSpinnerShards() {
+	if v.spinnerRetrieveStreams == nil {
+		return
+	}
+	done := 0
+	states := make([]string, len(v.shardPages))
+	for i := range v.shardPages {
+		if v.shardDone[i] {
+			done++
+			states[i] = "done"
+		} else {
+			states[i] = fmt.Sprintf("page %d", v.shardPages[i])
+		}
+	}
+	v.spinnerRetrieveStreams.UpdateText(fmt.Sprintf("Processing events... %d saved to database, shards %d/%d done [%s]",
+		v.nbEventsSaved.Load(), done, len(states), strings.Join(states, "|")))
+}